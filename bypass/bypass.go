@@ -18,11 +18,11 @@ func New() *Bypass {
 	return Instance
 }
 
-func (b *Bypass) Store(filePath, path string) (cLink string, err error) {
+func (b *Bypass) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
 	return "", ErrMethodNotImplemented
 }
 
-func (b *Bypass) StoreByCLink(filePath, cLink string) (err error) {
+func (b *Bypass) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
 	return ErrMethodNotImplemented
 }
 
@@ -0,0 +1,387 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+type (
+	Config struct {
+		StorageKey string
+		StorageCtl core.Storage
+		Dir        string // dedicated directory for cached objects
+		MaxSize    int64  // bytes, 0 - unlimited
+		BufferSize int    // bytes
+	}
+
+	CacheStorage struct {
+		cfg   Config
+		files *local.Local
+
+		mu       sync.Mutex
+		lru      *list.List
+		entries  map[string]*list.Element
+		inflight map[string]*fetchCall
+		size     int64
+		stats    Stats
+	}
+
+	Stats struct {
+		Hits      int64
+		Misses    int64
+		Evictions int64
+		Objects   int
+		Size      int64
+	}
+
+	entry struct {
+		path         string
+		size         int64
+		etag         string
+		lastModified time.Time
+	}
+
+	// fetchCall - download of the object shared by concurrent Open calls
+	fetchCall struct {
+		wg  sync.WaitGroup
+		err error
+	}
+)
+
+const (
+	defaultBufferSize = 32 * 1024
+
+	// validators of the wrapped object are kept in the metadata of the cached copy to restore the index
+	metaETag         = "cache-etag"
+	metaLastModified = "cache-last-modified"
+)
+
+func New(cfg *Config) *CacheStorage {
+	c := &CacheStorage{
+		cfg:      *cfg,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*fetchCall),
+	}
+
+	if c.cfg.BufferSize == 0 {
+		c.cfg.BufferSize = defaultBufferSize
+	}
+
+	c.files = local.New(&local.Config{
+		StorageKey: c.cfg.StorageKey,
+		Root:       c.cfg.Dir,
+		BufferSize: c.cfg.BufferSize,
+	})
+
+	c.restore()
+
+	return c
+}
+
+func (c *CacheStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	cLink, err = c.cfg.StorageCtl.Store(filePath, path, options...)
+	if err != nil {
+		return "", err // nolint:wrapcheck
+	}
+
+	c.Invalidate(cLink)
+
+	return cLink, nil
+}
+
+func (c *CacheStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
+	c.Invalidate(cLink)
+
+	return c.cfg.StorageCtl.StoreByCLink(filePath, cLink, options...)
+}
+
+func (c *CacheStorage) GetURL(cLink string, options ...interface{}) string {
+	return c.cfg.StorageCtl.GetURL(cLink, options...)
+}
+
+func (c *CacheStorage) Remove(cLink string) (err error) {
+	c.Invalidate(cLink)
+
+	return c.cfg.StorageCtl.Remove(cLink) // nolint:wrapcheck
+}
+
+func (c *CacheStorage) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	for _, cLink := range cLinks {
+		c.Invalidate(cLink)
+	}
+
+	return core.RemoveMany(c.cfg.StorageCtl, cLinks)
+}
+
+func (c *CacheStorage) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	results, err = core.RemovePrefix(c.cfg.StorageCtl, prefix)
+
+	for _, r := range results {
+		c.Invalidate(r.CLink)
+	}
+
+	return results, err // nolint:wrapcheck
+}
+
+func (c *CacheStorage) GetCLink(path string) (cLink string) {
+	return c.cfg.StorageCtl.GetCLink(path)
+}
+
+// Open returns the cached copy of the object if its ETag and last modified time
+// still match the Stat of the wrapped storage, otherwise downloads it to the cache.
+// Objects can't be validated without Stat, so they are not cached. Versions are read from the wrapped storage
+func (c *CacheStorage) Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	if core.ParseVersion(options...) != "" {
+		return core.Open(c.cfg.StorageCtl, cLink, options...) // nolint:wrapcheck
+	}
+
+	path := common.CLinkToPath(c.cfg.StorageKey, cLink)
+
+	info, err := core.Stat(c.cfg.StorageCtl, cLink)
+	if err != nil {
+		if errors.Is(err, core.ErrObjectNotFound) {
+			c.Invalidate(cLink)
+		}
+
+		if errors.Is(err, core.ErrNotSupported) {
+			return core.Open(c.cfg.StorageCtl, cLink, options...) // nolint:wrapcheck
+		}
+
+		return nil, err // nolint:wrapcheck
+	}
+
+	if info.ETag == "" && info.LastModified.IsZero() {
+		return core.Open(c.cfg.StorageCtl, cLink, options...) // nolint:wrapcheck
+	}
+
+	if c.hit(path, info) {
+		rc, err = c.files.Open(cLink)
+		if err == nil {
+			return rc, nil
+		}
+
+		c.Invalidate(cLink)
+	}
+
+	err = c.fetch(cLink, path, info, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.files.Open(cLink) // nolint:wrapcheck
+}
+
+func (c *CacheStorage) Unwrap() core.Storage {
+	return c.cfg.StorageCtl
+}
+
+// Invalidate drops the cached copy of the object
+func (c *CacheStorage) Invalidate(cLink string) {
+	path := common.CLinkToPath(c.cfg.StorageKey, cLink)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[path]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Stats returns hit/miss statistics of the cache
+func (c *CacheStorage) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Objects = c.lru.Len()
+	stats.Size = c.size
+
+	return stats
+}
+
+func (c *CacheStorage) hit(path string, info core.ObjectInfo) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[path]
+	if ok {
+		e := el.Value.(*entry)
+		ok = e.etag == info.ETag && e.lastModified.Equal(info.LastModified)
+
+		if !ok {
+			c.removeElement(el)
+		}
+	}
+
+	if !ok {
+		c.stats.Misses++
+		return false
+	}
+
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+
+	return true
+}
+
+// fetch downloads the object to the cache, concurrent calls for the path wait for the first one
+func (c *CacheStorage) fetch(cLink, path string, info core.ObjectInfo, options []interface{}) error {
+	c.mu.Lock()
+
+	if call, ok := c.inflight[path]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+
+		return call.err
+	}
+
+	call := &fetchCall{}
+	call.wg.Add(1)
+	c.inflight[path] = call
+
+	if el, ok := c.entries[path]; ok {
+		c.removeElement(el)
+	}
+
+	c.mu.Unlock()
+
+	call.err = c.download(cLink, path, info, options)
+
+	c.mu.Lock()
+	delete(c.inflight, path)
+	c.mu.Unlock()
+
+	call.wg.Done()
+
+	return call.err
+}
+
+// download copies the object to the cache directory without holding the lock and adds it to the index
+func (c *CacheStorage) download(cLink, path string, info core.ObjectInfo, options []interface{}) error {
+	rc, err := core.Open(c.cfg.StorageCtl, cLink, options...)
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp("", "cache-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, rc)
+	tmp.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", cLink, err)
+	}
+
+	err = c.files.StoreByCLink(tmp.Name(), cLink, core.UserMetadata{
+		metaETag:         info.ETag,
+		metaLastModified: info.LastModified.Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cache %s: %w", cLink, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.push(&entry{
+		path:         path,
+		size:         size,
+		etag:         info.ETag,
+		lastModified: info.LastModified,
+	})
+	c.evict()
+
+	return nil
+}
+
+// restore rebuilds the index from the cache directory, the most recently cached objects are used first.
+// Copies without validators are removed
+func (c *CacheStorage) restore() {
+	type restored struct {
+		entry  *entry
+		cached time.Time
+	}
+
+	var (
+		entries []restored
+		stale   []string
+	)
+
+	c.files.List("", func(info core.ObjectInfo) error { // nolint:errcheck
+		stat, err := c.files.Stat(info.CLink)
+		if err != nil {
+			return nil // nolint:nilerr
+		}
+
+		lastModified, err := time.Parse(time.RFC3339Nano, stat.Metadata[metaLastModified])
+		if err != nil {
+			stale = append(stale, info.CLink)
+			return nil // nolint:nilerr
+		}
+
+		entries = append(entries, restored{
+			entry: &entry{
+				path:         info.Path,
+				size:         info.Size,
+				etag:         stat.Metadata[metaETag],
+				lastModified: lastModified,
+			},
+			cached: info.LastModified,
+		})
+
+		return nil
+	})
+
+	for _, cLink := range stale {
+		c.files.Remove(cLink) // nolint:errcheck
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].cached.Before(entries[j].cached)
+	})
+
+	for _, r := range entries {
+		c.push(r.entry)
+	}
+
+	c.evict()
+}
+
+// push adds the entry as the most recently used one
+func (c *CacheStorage) push(e *entry) {
+	c.entries[e.path] = c.lru.PushFront(e)
+	c.size += e.size
+}
+
+// evict removes least recently used objects until the cache fits MaxSize.
+// The most recent object is kept even if it is larger than MaxSize
+func (c *CacheStorage) evict() {
+	for c.cfg.MaxSize > 0 && c.size > c.cfg.MaxSize && c.lru.Len() > 1 {
+		c.removeElement(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *CacheStorage) removeElement(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.path)
+	c.size -= e.size
+
+	c.files.Remove(c.files.GetCLink(e.path)) // nolint:errcheck
+}
@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+var testStorageKey = "cached"
+
+func newTestStorage(t *testing.T, maxSize int64) *CacheStorage {
+	return New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: local.New(&local.Config{
+			StorageKey: testStorageKey,
+			Root:       t.TempDir(),
+			BufferSize: 32 * 1024,
+		}),
+		Dir:     t.TempDir(),
+		MaxSize: maxSize,
+	})
+}
+
+func storeContent(t *testing.T, c *CacheStorage, path, content string) string {
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, []byte(content), 0o644)
+
+	cLink, err := c.Store(tmp, path)
+	if err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	return cLink
+}
+
+func readContent(t *testing.T, c *CacheStorage, cLink string) string {
+	rc, err := c.Open(cLink)
+	if err != nil {
+		t.Fatalf("Open err: %q", err)
+	}
+	defer rc.Close()
+
+	b, _ := ioutil.ReadAll(rc)
+
+	return string(b)
+}
+
+func TestOpen(t *testing.T) {
+	c := newTestStorage(t, 0)
+	cLink := storeContent(t, c, "folder/file.txt", "first")
+
+	flagtests := []struct {
+		content string
+		hits    int64
+		misses  int64
+	}{
+		{"first", 0, 1},
+		{"first", 1, 1},
+		{"first", 2, 1},
+	}
+
+	for _, tt := range flagtests {
+		if s := readContent(t, c, cLink); s != tt.content {
+			t.Errorf("got %q, want %q", s, tt.content)
+		}
+
+		stats := c.Stats()
+		if stats.Hits != tt.hits || stats.Misses != tt.misses {
+			t.Errorf("got %+v, want hits %d, misses %d", stats, tt.hits, tt.misses)
+		}
+	}
+
+	cLink = storeContent(t, c, "folder/file.txt", "second")
+
+	if s := readContent(t, c, cLink); s != "second" {
+		t.Errorf("got %q, want %q", s, "second")
+	}
+
+	if err := c.Remove(cLink); err != nil {
+		t.Fatalf("Remove err: %q", err)
+	}
+
+	if _, err := c.Open(cLink); err == nil {
+		t.Errorf("removed object should not be served from cache")
+	}
+
+	if stats := c.Stats(); stats.Objects != 0 || stats.Size != 0 {
+		t.Errorf("cache is not empty: %+v", stats)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	c := newTestStorage(t, 10)
+
+	first := storeContent(t, c, "first.txt", "123456")
+	second := storeContent(t, c, "second.txt", "123456")
+
+	readContent(t, c, first)
+	readContent(t, c, second)
+
+	stats := c.Stats()
+	if stats.Evictions != 1 || stats.Objects != 1 || stats.Size != 6 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	readContent(t, c, second)
+
+	if stats = c.Stats(); stats.Hits != 1 {
+		t.Errorf("got %d hits, want 1", stats.Hits)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	c := newTestStorage(t, 0)
+	cLink := storeContent(t, c, "folder/file.txt", "cached")
+	readContent(t, c, cLink)
+
+	restored := New(&c.cfg)
+
+	if stats := restored.Stats(); stats.Objects != 1 || stats.Size != 6 {
+		t.Fatalf("index is not restored: %+v", stats)
+	}
+
+	if s := readContent(t, restored, cLink); s != "cached" {
+		t.Errorf("got %q, want %q", s, "cached")
+	}
+
+	if stats := restored.Stats(); stats.Hits != 1 || stats.Misses != 0 {
+		t.Errorf("got %+v, want a hit", stats)
+	}
+}
+
+// noStatStorage hides Stat of the wrapped storage
+type noStatStorage struct {
+	core.Storage
+	opener core.Opener
+}
+
+func (s noStatStorage) Open(cLink string, options ...interface{}) (io.ReadCloser, error) {
+	return s.opener.Open(cLink, options...)
+}
+
+func TestOpenWithoutStat(t *testing.T) {
+	c := newTestStorage(t, 0)
+	cLink := storeContent(t, c, "file.txt", "first")
+
+	l := c.cfg.StorageCtl.(*local.Local)
+	c = New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: noStatStorage{Storage: l, opener: l},
+		Dir:        t.TempDir(),
+	})
+
+	readContent(t, c, cLink)
+	storeContent(t, c, "file.txt", "second")
+
+	if s := readContent(t, c, cLink); s != "second" {
+		t.Errorf("got %q, want %q", s, "second")
+	}
+
+	if stats := c.Stats(); stats.Objects != 0 {
+		t.Errorf("objects without Stat should not be cached: %+v", stats)
+	}
+}
+
+func TestConcurrentOpen(t *testing.T) {
+	c := newTestStorage(t, 0)
+	cLink := storeContent(t, c, "file.txt", "content")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if s := readContent(t, c, cLink); s != "content" {
+				t.Errorf("got %q, want %q", s, "content")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if stats := c.Stats(); stats.Objects != 1 || stats.Hits+stats.Misses != 10 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestOpenVersion(t *testing.T) {
+	l := local.New(&local.Config{
+		StorageKey: testStorageKey,
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+		Versioning: true,
+	})
+	c := New(&Config{StorageKey: testStorageKey, StorageCtl: l, Dir: t.TempDir()})
+
+	cLink := storeContent(t, c, "file.txt", "first")
+	storeContent(t, c, "file.txt", "second")
+	readContent(t, c, cLink)
+
+	versions, err := l.ListVersions(cLink)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("ListVersions: %+v, %v", versions, err)
+	}
+
+	rc, err := c.Open(cLink, core.WithVersion(versions[1].VersionID))
+	if err != nil {
+		t.Fatalf("Open err: %q", err)
+	}
+
+	b, _ := ioutil.ReadAll(rc)
+	rc.Close()
+
+	if string(b) != "first" {
+		t.Errorf("got %q, want the version", b)
+	}
+
+	if s := readContent(t, c, cLink); s != "second" {
+		t.Errorf("got %q, want %q", s, "second")
+	}
+}
@@ -3,7 +3,9 @@ package cloudfront
 import (
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"log"
 	"net/url"
 
 	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
@@ -26,6 +28,7 @@ type (
 	CFStorage struct {
 		cfg    Config
 		scheme string
+		signer *sign.URLSigner
 	}
 )
 
@@ -34,6 +37,8 @@ const (
 	SchemeHTTPWithoutSSL = "http"
 )
 
+var ErrInvalidPrivateKey = errors.New("invalid private key")
+
 func New(cfg *Config) *CFStorage {
 	scheme := SchemeHTTPWithSSL
 
@@ -41,18 +46,29 @@ func New(cfg *Config) *CFStorage {
 		scheme = SchemeHTTPWithoutSSL
 	}
 
-	return &CFStorage{
+	c := &CFStorage{
 		cfg:    *cfg,
 		scheme: scheme,
 	}
+
+	if c.cfg.SignURLs {
+		signer, err := newSigner(c.cfg.PrivateKeyID, c.cfg.PrivateKey)
+		if err != nil {
+			log.Printf("failed init url signer: %v", err)
+		}
+
+		c.signer = signer
+	}
+
+	return c
 }
 
 func (c *CFStorage) GetCLink(path string) (cLink string) {
 	return c.cfg.StorageCtl.GetCLink(path)
 }
 
-func (c *CFStorage) Store(filePath, path string) (cLink string, err error) {
-	cLink, err = c.cfg.StorageCtl.Store(filePath, path)
+func (c *CFStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	cLink, err = c.cfg.StorageCtl.Store(filePath, path, options...)
 	if err != nil {
 		return "", fmt.Errorf("failed to store %s: %w", path, err)
 	}
@@ -87,18 +103,12 @@ func (c *CFStorage) GetURL(cLink string, options ...interface{}) string {
 	var signed bool
 
 	for _, op := range options {
-		if expirationVerifier, ok := op.(core.ExpirationVerifier); ok {
+		if expirationVerifier, ok := op.(core.ExpirationVerifier); ok && c.signer != nil {
 			expire := expirationVerifier.GetAccessExpireTime(URL)
-			block, _ := pem.Decode([]byte(c.cfg.PrivateKey))
 
-			privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			URL, err = c.signer.Sign(URL, expire)
 			if err == nil {
-				signer := sign.NewURLSigner(c.cfg.PrivateKeyID, privateKey)
-
-				URL, err = signer.Sign(URL, expire)
-				if err == nil {
-					signed = true
-				}
+				signed = true
 			}
 		}
 	}
@@ -114,13 +124,39 @@ func (c *CFStorage) Remove(cLink string) (err error) {
 	return c.cfg.StorageCtl.Remove(cLink) // nolint:wrapcheck
 }
 
-func (c *CFStorage) StoreByCLink(filePath, cLink string) (err error) {
+func (c *CFStorage) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	return core.RemoveMany(c.cfg.StorageCtl, cLinks)
+}
+
+func (c *CFStorage) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	return core.RemovePrefix(c.cfg.StorageCtl, prefix) // nolint:wrapcheck
+}
+
+func (c *CFStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
 	path := common.CLinkToPath(c.cfg.StorageKey, cLink)
 
-	_, err = c.cfg.StorageCtl.Store(filePath, path)
+	_, err = c.cfg.StorageCtl.Store(filePath, path, options...)
 	if err != nil {
 		return fmt.Errorf("failed to store %s: %w", path, err)
 	}
 
 	return
 }
+
+func (c *CFStorage) Unwrap() core.Storage {
+	return c.cfg.StorageCtl
+}
+
+func newSigner(keyID, key string) (*sign.URLSigner, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPrivateKey, err)
+	}
+
+	return sign.NewURLSigner(keyID, privateKey), nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/configor"
+	"github.com/rosberry/storage"
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	// config - the same format as example/config
+	config struct {
+		Storages core.StoragesConfig `json:"storages" yaml:"storages"`
+	}
+
+	app struct {
+		storage *core.AbstractStorage
+		in      io.Reader
+		out     io.Writer
+		json    bool
+	}
+
+	// usageError - invalid arguments of the command
+	usageError string
+
+	// expiresAt - GetURL option, lifetime of signed URLs (cloudfront with signing keys)
+	expiresAt time.Time
+
+	// object - ObjectInfo in JSON output
+	object struct {
+		CLink              string            `json:"clink"`
+		Path               string            `json:"path"`
+		Size               int64             `json:"size"`
+		ContentType        string            `json:"content_type,omitempty"`
+		ContentEncoding    string            `json:"content_encoding,omitempty"`
+		CacheControl       string            `json:"cache_control,omitempty"`
+		ContentDisposition string            `json:"content_disposition,omitempty"`
+		ContentLanguage    string            `json:"content_language,omitempty"`
+		Metadata           map[string]string `json:"metadata,omitempty"`
+		ETag               string            `json:"etag,omitempty"`
+		LastModified       *time.Time        `json:"last_modified,omitempty"`
+		ExpiresAt          *time.Time        `json:"expires_at,omitempty"`
+	}
+
+	// result - item of put, rm and cp JSON output
+	result struct {
+		CLink string `json:"clink"`
+		Error string `json:"error,omitempty"`
+	}
+)
+
+func newApp(configPath string, in io.Reader, out io.Writer, jsonOutput bool) (*app, error) {
+	var cfg config
+
+	if err := configor.Load(&cfg, configPath); err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if len(cfg.Storages.Instances) == 0 {
+		return nil, fmt.Errorf("%s: no storage instances", configPath)
+	}
+
+	return &app{
+		storage: storage.NewWithConfig(&cfg.Storages),
+		in:      in,
+		out:     out,
+		json:    jsonOutput,
+	}, nil
+}
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func (e expiresAt) GetAccessExpireTime(cLink string) time.Time {
+	return time.Time(e)
+}
+
+// print writes v as one line of JSON or text by fn
+func (a *app) print(v interface{}, text func(w io.Writer)) error {
+	if !a.json {
+		text(a.out)
+		return nil
+	}
+
+	if err := json.NewEncoder(a.out).Encode(v); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
+
+// splitCLink splits "key:path" into the storage key and the path
+func splitCLink(cLink string) (key, path string, err error) {
+	parts := strings.SplitN(cLink, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", usageError(fmt.Sprintf("%s: want key:path", cLink))
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// tempFile copies r to the temp file, the caller removes it
+func tempFile(r io.Reader) (string, error) {
+	f, err := os.CreateTemp("", "storagectl-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err = io.Copy(f, r); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+func newObject(info core.ObjectInfo) object {
+	o := object{
+		CLink:              info.CLink,
+		Path:               info.Path,
+		Size:               info.Size,
+		ContentType:        info.ContentType,
+		ContentEncoding:    info.ContentEncoding,
+		CacheControl:       info.CacheControl,
+		ContentDisposition: info.ContentDisposition,
+		ContentLanguage:    info.ContentLanguage,
+		Metadata:           info.Metadata,
+		ETag:               info.ETag,
+	}
+
+	if !info.LastModified.IsZero() {
+		o.LastModified = &info.LastModified
+	}
+
+	if !info.ExpiresAt.IsZero() {
+		o.ExpiresAt = &info.ExpiresAt
+	}
+
+	return o
+}
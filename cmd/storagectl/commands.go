@@ -0,0 +1,443 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/migrate"
+)
+
+// withoutConfig - commands that do not use storages
+var withoutConfig = map[string]bool{
+	"rewrite": true,
+}
+
+var commands = map[string]func(a *app, args []string) error{
+	"put":  put,
+	"get":  get,
+	"url":  url,
+	"rm":   rm,
+	"ls":   ls,
+	"stat": stat,
+	"cp":   cp,
+
+	"migrate": migrateCmd,
+	"rewrite": rewrite,
+}
+
+func put(a *app, args []string) error {
+	fs := newFlagSet("put")
+	contentType := fs.String("content-type", "", "content type, detected by content if empty")
+	public := fs.Bool("public", false, "make the object public")
+
+	if err := parse(fs, args, 2, 2); err != nil {
+		return err
+	}
+
+	key, path, err := splitCLink(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	filePath := fs.Arg(0)
+
+	if filePath == "-" {
+		if filePath, err = tempFile(a.in); err != nil {
+			return err
+		}
+		defer os.Remove(filePath)
+	}
+
+	var options []interface{}
+
+	if *contentType != "" {
+		options = append(options, core.ContentType(*contentType))
+	}
+
+	if *public {
+		options = append(options, core.VisibilityPublic)
+	}
+
+	cLink, err := a.storage.CreateCLinkInStorage(filePath, path, key, options...)
+	if err != nil {
+		return fmt.Errorf("failed to store %s: %w", fs.Arg(0), err)
+	}
+
+	return a.print(result{CLink: cLink}, func(w io.Writer) {
+		fmt.Fprintln(w, cLink)
+	})
+}
+
+func get(a *app, args []string) error {
+	fs := newFlagSet("get")
+
+	if err := parse(fs, args, 1, 2); err != nil {
+		return err
+	}
+
+	rc, err := a.storage.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", fs.Arg(0), err)
+	}
+	defer rc.Close()
+
+	dst := fs.Arg(1)
+	if dst == "" || dst == "-" {
+		if _, err = io.Copy(a.out, rc); err != nil {
+			return fmt.Errorf("failed to read %s: %w", fs.Arg(0), err)
+		}
+
+		return nil
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	_, err = io.Copy(f, rc)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	// partial file is not left as if it was downloaded
+	if err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("failed to read %s: %w", fs.Arg(0), err)
+	}
+
+	return nil
+}
+
+func url(a *app, args []string) error {
+	fs := newFlagSet("url")
+	expires := fs.Duration("expires", 0, "lifetime of the signed URL, if the storage supports it")
+	public := fs.Bool("public", false, "unsigned URL of the public object")
+
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	var options []interface{}
+
+	if *expires > 0 {
+		options = append(options, expiresAt(time.Now().Add(*expires)))
+	}
+
+	if *public {
+		options = append(options, core.VisibilityPublic)
+	}
+
+	u := a.storage.GetURL(fs.Arg(0), options...)
+	if u == "" {
+		return fmt.Errorf("%s: failed to get URL", fs.Arg(0))
+	}
+
+	return a.print(map[string]string{"url": u}, func(w io.Writer) {
+		fmt.Fprintln(w, u)
+	})
+}
+
+func rm(a *app, args []string) error {
+	fs := newFlagSet("rm")
+	prefix := fs.Bool("prefix", false, "arguments are key:prefix, remove all objects by the prefix")
+
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	var (
+		results   []core.DeleteResult
+		prefixErr error
+	)
+
+	if *prefix {
+		// arguments are checked before anything is removed
+		for _, arg := range fs.Args() {
+			if _, _, err := splitCLink(arg); err != nil {
+				return err
+			}
+		}
+
+		for _, arg := range fs.Args() {
+			key, p, _ := splitCLink(arg)
+
+			r, err := a.storage.DeletePrefix(key, p)
+			results = append(results, r...)
+
+			// objects removed before the error are printed anyway
+			if err != nil {
+				prefixErr = fmt.Errorf("failed to remove %s: %w", arg, err)
+				break
+			}
+		}
+	} else {
+		results = a.storage.DeleteMany(fs.Args())
+	}
+
+	failed := 0
+
+	for _, r := range results {
+		out := result{CLink: r.CLink}
+		if r.Err != nil {
+			out.Error = r.Err.Error()
+			failed++
+		}
+
+		err := a.print(out, func(w io.Writer) {
+			if r.Err != nil {
+				fmt.Fprintf(w, "%s\t%v\n", r.CLink, r.Err)
+				return
+			}
+			fmt.Fprintln(w, r.CLink)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if prefixErr != nil {
+		return prefixErr
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d objects are not removed", failed, len(results))
+	}
+
+	return nil
+}
+
+func ls(a *app, args []string) error {
+	fs := newFlagSet("ls")
+
+	if err := parse(fs, args, 1, 2); err != nil {
+		return err
+	}
+
+	err := a.storage.List(fs.Arg(0), fs.Arg(1), func(info core.ObjectInfo) error {
+		return a.print(newObject(info), func(w io.Writer) {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", info.Size, info.LastModified.UTC().Format(time.RFC3339), info.CLink)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", fs.Arg(0), err)
+	}
+
+	return nil
+}
+
+func stat(a *app, args []string) error {
+	fs := newFlagSet("stat")
+
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	info, err := a.storage.Stat(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", fs.Arg(0), err)
+	}
+
+	return a.print(newObject(info), func(w io.Writer) {
+		fields := [][2]string{
+			{"clink", info.CLink},
+			{"path", info.Path},
+			{"size", fmt.Sprint(info.Size)},
+			{"content-type", info.ContentType},
+			{"content-encoding", info.ContentEncoding},
+			{"cache-control", info.CacheControl},
+			{"content-disposition", info.ContentDisposition},
+			{"content-language", info.ContentLanguage},
+			{"etag", info.ETag},
+		}
+
+		if !info.LastModified.IsZero() {
+			fields = append(fields, [2]string{"last-modified", info.LastModified.UTC().Format(time.RFC3339)})
+		}
+
+		if !info.ExpiresAt.IsZero() {
+			fields = append(fields, [2]string{"expires-at", info.ExpiresAt.UTC().Format(time.RFC3339)})
+		}
+
+		keys := make([]string, 0, len(info.Metadata))
+		for k := range info.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fields = append(fields, [2]string{"meta-" + k, info.Metadata[k]})
+		}
+
+		for _, f := range fields {
+			if f[1] != "" {
+				fmt.Fprintf(w, "%s: %s\n", f[0], f[1])
+			}
+		}
+	})
+}
+
+func cp(a *app, args []string) error {
+	fs := newFlagSet("cp")
+
+	if err := parse(fs, args, 2, 2); err != nil {
+		return err
+	}
+
+	src, dst := fs.Arg(0), fs.Arg(1)
+
+	if _, _, err := splitCLink(dst); err != nil {
+		return err
+	}
+
+	// headers and metadata are copied if the source can describe its objects
+	info, err := a.storage.Stat(src)
+	if err != nil && !errors.Is(err, core.ErrNotSupported) {
+		return fmt.Errorf("failed to stat %s: %w", src, err)
+	}
+
+	// the bytes are copied as stored, Content-Encoding of the metadata describes them
+	rc, err := a.storage.OpenRange(src, 0, -1)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+
+	tmp, err := tempFile(rc)
+	rc.Close()
+
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err = a.storage.UploadByCLink(tmp, dst, core.MetadataOptions(info)...); err != nil {
+		return fmt.Errorf("failed to store %s: %w", dst, err)
+	}
+
+	return a.print(result{CLink: dst}, func(w io.Writer) {
+		fmt.Fprintln(w, dst)
+	})
+}
+
+func migrateCmd(a *app, args []string) error {
+	fs := newFlagSet("migrate")
+	prefix := fs.String("prefix", "", "copy only objects which path starts with prefix")
+	workers := fs.Int("workers", migrate.DefaultWorkers, "parallel copies")
+	checkpoint := fs.String("checkpoint", "", "file with copied paths to resume the migration")
+	dryRun := fs.Bool("dry-run", false, "only count objects to copy")
+
+	if err := parse(fs, args, 2, 2); err != nil {
+		return err
+	}
+
+	src, err := a.storage.GetStorage(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	dst, err := a.storage.GetStorage(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(1), err)
+	}
+
+	report, err := migrate.Copy(&migrate.Config{
+		Source:     src,
+		Target:     dst,
+		Prefix:     *prefix,
+		Workers:    *workers,
+		Checkpoint: *checkpoint,
+		DryRun:     *dryRun,
+	})
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	failed := make([]result, len(report.Failed))
+	for i, f := range report.Failed {
+		failed[i] = result{CLink: src.GetCLink(f.Path), Error: f.Err.Error()}
+	}
+
+	err = a.print(map[string]interface{}{
+		"listed":  report.Listed,
+		"skipped": report.Skipped,
+		"copied":  report.Copied,
+		"bytes":   report.Bytes,
+		"failed":  failed,
+		"dry_run": *dryRun,
+	}, func(w io.Writer) {
+		for _, f := range failed {
+			fmt.Fprintf(w, "%s\t%s\n", f.CLink, f.Error)
+		}
+
+		verb := "copied"
+		if *dryRun {
+			verb = "to copy"
+		}
+
+		fmt.Fprintf(w, "listed %d, skipped %d, %s %d (%d bytes), failed %d\n",
+			report.Listed, report.Skipped, verb, report.Copied, report.Bytes, len(failed))
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d objects are not copied, run again to retry", len(failed))
+	}
+
+	return nil
+}
+
+func rewrite(a *app, args []string) error {
+	fs := newFlagSet("rewrite")
+	dryRun := fs.Bool("dry-run", false, "only count cLinks to rewrite")
+
+	if err := parse(fs, args, 2, 2); err != nil {
+		return err
+	}
+
+	w := a.out
+	if *dryRun {
+		w = ioutil.Discard
+	}
+
+	replaced, err := migrate.NewRewriter(fs.Arg(0), fs.Arg(1)).Rewrite(a.in, w)
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	if *dryRun {
+		return a.print(map[string]int{"replaced": replaced}, func(w io.Writer) {
+			fmt.Fprintf(w, "%d cLinks to rewrite\n", replaced)
+		})
+	}
+
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	return fs
+}
+
+// parse parses flags of the command and checks the number of arguments, max < 0 - unlimited
+func parse(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return usageError(fmt.Sprintf("%s: %v", fs.Name(), err))
+	}
+
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		return usageError(fmt.Sprintf("%s: wrong number of arguments, see storagectl -h", fs.Name()))
+	}
+
+	return nil
+}
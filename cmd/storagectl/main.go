@@ -0,0 +1,119 @@
+// storagectl - command-line access to storages described by core.StoragesConfig.
+//
+// Usage:
+//
+//	storagectl [-config .env.yml] [-json] <command> [flags] [args]
+//
+// Commands:
+//
+//	put [-content-type type] [-public] <file|-> <key:path>
+//	get <cLink> [file|-]
+//	url [-expires 1h] [-public] <cLink>
+//	rm [-prefix] <cLink|key:prefix>...
+//	ls <key> [prefix]
+//	stat <cLink>
+//	cp <src cLink> <dst cLink>
+//	migrate [-prefix p] [-workers 4] [-checkpoint file] [-dry-run] <src key> <dst key>
+//	rewrite [-dry-run] <old key> <new key>
+//
+// "-" is stdin for put and stdout for get. The config has the same format as in example/config:
+//
+//	storages:
+//	  instances:
+//	    - key: "files"
+//	      type: "local"
+//	      config: {...}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rosberry/storage/core"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns the exit code: 0 - success, 1 - failure, 2 - invalid usage
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("storagectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+
+	configPath := fs.String("config", envOr("STORAGECTL_CONFIG", ".env.yml"), "path to the config file")
+	jsonOutput := fs.Bool("json", false, "print results as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+
+	a := &app{storage: core.New(), in: stdin, out: stdout, json: *jsonOutput}
+
+	if !withoutConfig[fs.Arg(0)] {
+		var err error
+
+		if a, err = newApp(*configPath, stdin, stdout, *jsonOutput); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailure
+		}
+	}
+	defer a.storage.Close() // nolint:errcheck
+
+	if err := cmd(a, fs.Args()[1:]); err != nil {
+		fmt.Fprintln(stderr, err)
+
+		if _, ok := err.(usageError); ok { // nolint:errorlint
+			return exitUsage
+		}
+
+		return exitFailure
+	}
+
+	return exitSuccess
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+const usage = `usage: storagectl [-config .env.yml] [-json] <command> [flags] [args]
+
+commands:
+  put [-content-type type] [-public] <file|-> <key:path>   store file or stdin, prints cLink
+  get <cLink> [file|-]                                      write object to file or stdout
+  url [-expires 1h] [-public] <cLink>                       print URL of the object
+  rm [-prefix] <cLink|key:prefix>...                        remove objects or all objects by prefix
+  ls <key> [prefix]                                         list objects of the storage
+  stat <cLink>                                              print info about the object
+  cp <src cLink> <dst cLink>                                copy object with its metadata
+  migrate [-prefix p] [-workers 4] [-checkpoint file] [-dry-run] <src key> <dst key>
+                                                            copy all objects to another storage
+  rewrite [-dry-run] <old key> <new key>                    replace cLinks of old key in stdin, write to stdout
+
+the config path can be set by STORAGECTL_CONFIG
+`
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestConfig(t *testing.T) string {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yml")
+
+	ioutil.WriteFile(configPath, []byte(`storages:
+  instances:
+    - key: "files"
+      type: "local"
+      config:
+        root: "`+filepath.Join(dir, "root")+`"
+        endpoint: "http://url.com/files"
+    - key: "backup"
+      type: "local"
+      config:
+        root: "`+filepath.Join(dir, "backup")+`"
+`), 0o644)
+
+	return configPath
+}
+
+func TestRun(t *testing.T) {
+	configPath := newTestConfig(t)
+
+	exec := func(stdin string, args ...string) (string, int) {
+		var stdout, stderr bytes.Buffer
+
+		code := run(append([]string{"-config", configPath}, args...), strings.NewReader(stdin), &stdout, &stderr)
+		if code != 0 {
+			t.Logf("%v: %s", args, stderr.String())
+		}
+
+		return stdout.String(), code
+	}
+
+	flagtests := []struct {
+		name  string
+		stdin string
+		args  []string
+		out   string
+		code  int
+	}{
+		{"put", "hello", []string{"put", "-content-type", "text/plain", "-", "files:docs/a.txt"}, "files:docs/a.txt\n", 0},
+		{"get", "", []string{"get", "files:docs/a.txt"}, "hello", 0},
+		{"cp", "", []string{"cp", "files:docs/a.txt", "files:docs/b.txt"}, "files:docs/b.txt\n", 0},
+		{"url", "", []string{"url", "files:docs/b.txt"}, "http://url.com/files/docs/b.txt\n", 0},
+		{"stat", "", []string{"-json", "stat", "files:docs/b.txt"}, `"content_type":"text/plain"`, 0},
+		{"ls", "", []string{"ls", "files", "docs/"}, "files:docs/b.txt\n", 0},
+		{"migrate dry run", "", []string{"migrate", "-dry-run", "files", "backup"}, "to copy 2 (10 bytes)", 0},
+		{"migrate", "", []string{"migrate", "files", "backup"}, "copied 2 (10 bytes)", 0},
+		{"migrated", "", []string{"get", "backup:docs/b.txt"}, "hello", 0},
+		{"rewrite", `{"file":"files:docs/a.txt"}`, []string{"rewrite", "files", "backup"}, `{"file":"backup:docs/a.txt"}`, 0},
+		{"rm", "", []string{"rm", "-prefix", "files:docs/", "unknown:docs/"}, "files:docs/a.txt\nfiles:docs/b.txt\n", 1},
+		{"rm missing", "", []string{"rm", "files:docs/a.txt"}, "files:docs/a.txt\t", 1},
+		{"usage", "", []string{"cp", "files:docs/a.txt"}, "", 2},
+		{"unknown", "", []string{"mv"}, "", 2},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, code := exec(tt.stdin, tt.args...)
+			if code != tt.code || !strings.Contains(out, tt.out) {
+				t.Errorf("got %q, %d, want %q, %d", out, code, tt.out, tt.code)
+			}
+		})
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	configPath := newTestConfig(t)
+
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"-config", configPath, "-json", "put", "-", "files:a.txt"}, strings.NewReader("a"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("put: %s", stderr.String())
+	}
+
+	var r result
+	if err := json.Unmarshal(stdout.Bytes(), &r); err != nil || r.CLink != "files:a.txt" {
+		t.Errorf("unexpected output %q, err %v", stdout.String(), err)
+	}
+}
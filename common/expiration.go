@@ -0,0 +1,43 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Objects with expiration are tagged with ExpirationTag=<days>,
+// bucket lifecycle rules with ExpirationRulePrefix IDs remove them after <days> from creation
+const (
+	ExpirationTag        = "storage-expire-days"
+	ExpirationRulePrefix = "storage-expire-"
+
+	DefaultMaxExpirationDays = 30
+
+	day = 24 * time.Hour
+)
+
+var ErrInvalidExpiration = errors.New("invalid expiration")
+
+// ExpirationDays returns number of days until expiresAt rounded up,
+// lifecycle rules work with days only so objects live not less than requested
+func ExpirationDays(expiresAt time.Time, maxDays int) (days int, err error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%w: %s is in the past", ErrInvalidExpiration, expiresAt.Format(time.RFC3339))
+	}
+
+	days = int((ttl + day - 1) / day)
+
+	if maxDays > 0 && days > maxDays {
+		return 0, fmt.Errorf("%w: %d days is longer than %d", ErrInvalidExpiration, days, maxDays)
+	}
+
+	return days, nil
+}
+
+// ExpirationRuleID is ID of the lifecycle rule for objects tagged with days
+func ExpirationRuleID(days int) string {
+	return ExpirationRulePrefix + strconv.Itoa(days) + "d"
+}
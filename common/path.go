@@ -0,0 +1,90 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// PathPolicy - rules for paths (object keys) accepted by the storages
+type PathPolicy struct {
+	MaxKeyLength   int    // bytes, 0 - unlimited
+	ForbiddenChars string // forbidden in addition to control characters
+	Lowercase      bool
+}
+
+const defaultMaxKeyLength = 1024
+
+var (
+	ErrInvalidPath   = errors.New("invalid path")
+	ErrPathTraversal = errors.New("path traversal")
+
+	// DefaultPathPolicy is used by storages without configured policy
+	DefaultPathPolicy = PathPolicy{
+		MaxKeyLength: defaultMaxKeyLength,
+	}
+)
+
+// Normalize path by the policy
+//
+//   - Unicode is normalized to NFC
+//   - repeated, leading and trailing slashes are removed
+//   - "." and ".." segments are rejected with ErrPathTraversal
+//   - control characters and ForbiddenChars are rejected with ErrInvalidPath
+//
+// Returns path in the same form as accepted by PathToCLink and PathToInternalPath
+func (p PathPolicy) Normalize(path string) (normalized string, err error) {
+	if !utf8.ValidString(path) {
+		return "", fmt.Errorf("%w: not valid UTF-8", ErrInvalidPath)
+	}
+
+	path = norm.NFC.String(path)
+
+	for _, r := range path {
+		if unicode.IsControl(r) || strings.ContainsRune(p.ForbiddenChars, r) {
+			return "", fmt.Errorf("%w: forbidden character %q", ErrInvalidPath, r)
+		}
+	}
+
+	segments := make([]string, 0, strings.Count(path, "/")+1)
+
+	for _, s := range strings.Split(path, "/") {
+		switch s {
+		case "":
+			continue
+		case ".", "..":
+			return "", fmt.Errorf("%w: %s", ErrPathTraversal, path)
+		}
+
+		segments = append(segments, s)
+	}
+
+	normalized = strings.Join(segments, "/")
+
+	if p.Lowercase {
+		normalized = strings.ToLower(normalized)
+	}
+
+	if normalized == "" {
+		return "", fmt.Errorf("%w: empty path", ErrInvalidPath)
+	}
+
+	if p.MaxKeyLength > 0 && len(normalized) > p.MaxKeyLength {
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidPath, p.MaxKeyLength)
+	}
+
+	return normalized, nil
+}
+
+// GetPathPolicy returns policy or DefaultPathPolicy if policy is nil
+func GetPathPolicy(policy *PathPolicy) PathPolicy {
+	if policy == nil {
+		return DefaultPathPolicy
+	}
+
+	return *policy
+}
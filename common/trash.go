@@ -0,0 +1,39 @@
+package common
+
+import (
+	"strings"
+	"time"
+)
+
+// Deleted objects are moved to TrashDir/<path> by storages in trash mode
+const (
+	TrashDir = ".trash"
+
+	DefaultTrashRetention = 30 * day
+)
+
+// TrashPath returns path of the deleted object in trash
+func TrashPath(path string) string {
+	return TrashDir + "/" + strings.Trim(path, "/")
+}
+
+// IsTrashPath reports whether path is inside trash
+func IsTrashPath(path string) bool {
+	path = strings.TrimLeft(path, "/")
+
+	return path == TrashDir || strings.HasPrefix(path, TrashDir+"/")
+}
+
+// GetTrashRetention returns retention or DefaultTrashRetention if it is not set
+func GetTrashRetention(retention time.Duration) time.Duration {
+	if retention <= 0 {
+		return DefaultTrashRetention
+	}
+
+	return retention
+}
+
+// RetentionDays returns retention rounded up to days for lifecycle rules
+func RetentionDays(retention time.Duration) int {
+	return int((GetTrashRetention(retention) + day - 1) / day)
+}
@@ -41,6 +41,18 @@ func PathToInternalPath(prefix, path string) (internalPath string) {
 	return endSlash(prefix) + strings.Trim(path, "/")
 }
 
+// path prefix -> internal path prefix
+//
+// Same as PathToInternalPath, but keeps the trailing slash of the directory prefix
+func PrefixToInternalPrefix(prefix, pathPrefix string) (internalPrefix string) {
+	internalPrefix = PathToInternalPath(prefix, pathPrefix)
+	if strings.HasSuffix(pathPrefix, "/") {
+		internalPrefix = endSlash(internalPrefix)
+	}
+
+	return internalPrefix
+}
+
 // internalPath -> path
 //
 // Backward convertation after PathToInternalPath(prefix, path string) (internalPath string)
@@ -0,0 +1,206 @@
+package compress
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	Config struct {
+		StorageKey   string
+		StorageCtl   core.Storage
+		Algorithm    string   // gzip or zstd
+		Level        int      // 0 - default level of the algorithm
+		ContentTypes []string // content type prefixes, e.g. "text/", "application/json"
+		PathPatterns []string // path.Match patterns, e.g. "logs/*.log", "*.json"
+		TempDir      string
+	}
+
+	CompressStorage struct {
+		cfg Config
+	}
+)
+
+const (
+	AlgorithmGzip = "gzip"
+	AlgorithmZstd = "zstd"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown compression algorithm")
+
+func New(cfg *Config) *CompressStorage {
+	c := &CompressStorage{
+		cfg: *cfg,
+	}
+
+	if c.cfg.Algorithm == "" {
+		c.cfg.Algorithm = AlgorithmGzip
+	}
+
+	return c
+}
+
+func (c *CompressStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	tmpPath, options, err := c.prepare(filePath, path, options)
+	if err != nil {
+		return "", err
+	}
+
+	if tmpPath != "" {
+		defer os.Remove(tmpPath)
+		filePath = tmpPath
+	}
+
+	return c.cfg.StorageCtl.Store(filePath, path, options...)
+}
+
+func (c *CompressStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
+	path := common.CLinkToPath(c.cfg.StorageKey, cLink)
+
+	tmpPath, options, err := c.prepare(filePath, path, options)
+	if err != nil {
+		return err
+	}
+
+	if tmpPath != "" {
+		defer os.Remove(tmpPath)
+		filePath = tmpPath
+	}
+
+	return c.cfg.StorageCtl.StoreByCLink(filePath, cLink, options...)
+}
+
+func (c *CompressStorage) GetURL(cLink string, options ...interface{}) string {
+	return c.cfg.StorageCtl.GetURL(cLink, options...)
+}
+
+func (c *CompressStorage) Remove(cLink string) (err error) {
+	return c.cfg.StorageCtl.Remove(cLink) // nolint:wrapcheck
+}
+
+func (c *CompressStorage) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	return core.RemoveMany(c.cfg.StorageCtl, cLinks)
+}
+
+func (c *CompressStorage) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	return core.RemovePrefix(c.cfg.StorageCtl, prefix) // nolint:wrapcheck
+}
+
+func (c *CompressStorage) GetCLink(path string) (cLink string) {
+	return c.cfg.StorageCtl.GetCLink(path)
+}
+
+// Open returns the decompressed content of the object.
+// The algorithm is taken from Content-Encoding of the stored object, so objects
+// stored without this wrapper, e.g. user's .gz files, are returned as is.
+// Versions are decoded by Content-Encoding of the current object
+func (c *CompressStorage) Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	info, err := core.Stat(c.cfg.StorageCtl, cLink)
+	if err != nil && !errors.Is(err, core.ErrNotSupported) {
+		return nil, err // nolint:wrapcheck
+	}
+
+	rc, err = core.Open(c.cfg.StorageCtl, cLink, options...)
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+
+	return newReader(rc, info.ContentEncoding)
+}
+
+// OpenRange reads the object as stored, without decompression: offset and length
+// are counted in stored bytes as Size and ContentEncoding of Stat describe them
+func (c *CompressStorage) OpenRange(cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error) {
+	return core.OpenRange(c.cfg.StorageCtl, cLink, offset, length, options...) // nolint:wrapcheck
+}
+
+func (c *CompressStorage) Unwrap() core.Storage {
+	return c.cfg.StorageCtl
+}
+
+// prepare compresses the file to the temporary file if it matches the config
+// and returns its path with options describing the encoding
+func (c *CompressStorage) prepare(filePath, path string, options []interface{}) (tmpPath string, opts []interface{}, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	opts = options
+
+	storeOpts := core.ParseStoreOptions(options...)
+	if storeOpts.ContentEncoding != "" {
+		return "", opts, nil
+	}
+
+	contentType := storeOpts.ContentType
+	if contentType == "" {
+		contentType = common.GetFileContentType(f)
+	}
+
+	if !c.match(path, contentType) {
+		return "", opts, nil
+	}
+
+	tmp, err := os.CreateTemp(c.cfg.TempDir, "compress-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer tmp.Close()
+
+	err = c.compress(tmp, f)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", nil, err
+	}
+
+	opts = append(opts, core.ContentType(contentType), core.ContentEncoding(c.cfg.Algorithm))
+
+	return tmp.Name(), opts, nil
+}
+
+func (c *CompressStorage) match(p, contentType string) bool {
+	if len(c.cfg.ContentTypes) == 0 && len(c.cfg.PathPatterns) == 0 {
+		return !isCompressed(contentType)
+	}
+
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+
+	for _, t := range c.cfg.ContentTypes {
+		if strings.HasPrefix(mediaType, t) {
+			return true
+		}
+	}
+
+	p = strings.Trim(p, "/")
+
+	for _, pattern := range c.cfg.PathPatterns {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isCompressed(contentType string) bool {
+	switch strings.Split(contentType, ";")[0] {
+	case "application/x-gzip", "application/zip", "application/x-rar-compressed",
+		"image/jpeg", "image/png", "image/gif", "image/webp", "video/mp4", "video/webm",
+		"audio/mpeg":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,140 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rosberry/storage/local"
+)
+
+var (
+	testStorageKey = "zfile"
+	testContent    = []byte(strings.Repeat(`{"name":"test","value":42}`+"\n", 100))
+)
+
+func newTestStorage(t *testing.T, algorithm string) (*CompressStorage, string) {
+	root := t.TempDir()
+
+	return New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: local.New(&local.Config{
+			StorageKey: testStorageKey,
+			Root:       root,
+			BufferSize: 32 * 1024,
+		}),
+		Algorithm:    algorithm,
+		ContentTypes: []string{"application/json"},
+		PathPatterns: []string{"*.json", "logs/*"},
+	}), root
+}
+
+func TestStoreAndOpen(t *testing.T) {
+	flagtests := []struct {
+		algorithm  string
+		path       string
+		compressed bool
+	}{
+		{AlgorithmGzip, "exports/data.json", true},
+		{AlgorithmGzip, "logs/app", true},
+		{AlgorithmGzip, "exports/data.txt", false},
+		{AlgorithmZstd, "exports/data.json", true},
+		{AlgorithmZstd, "exports/data.txt", false},
+	}
+
+	tmp := filepath.Join(t.TempDir(), "data")
+	ioutil.WriteFile(tmp, testContent, 0o644)
+
+	for _, tt := range flagtests {
+		t.Run(tt.algorithm+":"+tt.path, func(t *testing.T) {
+			s, root := newTestStorage(t, tt.algorithm)
+
+			cLink, err := s.Store(tmp, tt.path)
+			if err != nil {
+				t.Fatalf("Store err: %q", err)
+			}
+
+			stored, err := ioutil.ReadFile(filepath.Join(root, tt.path))
+			if err != nil {
+				t.Fatalf("Read stored file err: %q", err)
+			}
+
+			if compressed := !bytes.Equal(stored, testContent); compressed != tt.compressed {
+				t.Errorf("got compressed %v, want %v", compressed, tt.compressed)
+			}
+
+			rc, err := s.Open(cLink)
+			if err != nil {
+				t.Fatalf("Open err: %q", err)
+			}
+			defer rc.Close()
+
+			content, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("Read err: %q", err)
+			}
+
+			if !bytes.Equal(content, testContent) {
+				t.Errorf("content of %q is not equal to original", cLink)
+			}
+		})
+	}
+
+	os.Remove(tmp)
+}
+
+func TestMatch(t *testing.T) {
+	s := New(&Config{})
+
+	flagtests := []struct {
+		path        string
+		contentType string
+		out         bool
+	}{
+		{"file.txt", "text/plain; charset=utf-8", true},
+		{"file.json", "application/json", true},
+		{"image.jpg", "image/jpeg", false},
+		{"archive.gz", "application/x-gzip", false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := s.match(tt.path, tt.contentType); got != tt.out {
+				t.Errorf("got %v, want %v", got, tt.out)
+			}
+		})
+	}
+}
+
+func TestOpenGzipFile(t *testing.T) {
+	s, _ := newTestStorage(t, AlgorithmGzip)
+
+	var gz bytes.Buffer
+
+	w := gzip.NewWriter(&gz)
+	w.Write(testContent) // nolint:errcheck
+	w.Close()
+
+	tmp := filepath.Join(t.TempDir(), "backup.gz")
+	ioutil.WriteFile(tmp, gz.Bytes(), 0o644)
+
+	cLink, err := s.Store(tmp, "backups/backup.gz")
+	if err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	rc, err := s.Open(cLink)
+	if err != nil {
+		t.Fatalf("Open err: %q", err)
+	}
+	defer rc.Close()
+
+	// the file is not stored by the wrapper, it is returned as is
+	if content, _ := ioutil.ReadAll(rc); !bytes.Equal(content, gz.Bytes()) {
+		t.Errorf("gzip file of the user is decompressed")
+	}
+}
@@ -0,0 +1,98 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func (c *CompressStorage) compress(dst io.Writer, src io.Reader) (err error) {
+	var w io.WriteCloser
+
+	switch c.cfg.Algorithm {
+	case AlgorithmGzip:
+		level := c.cfg.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+
+		w, err = gzip.NewWriterLevel(dst, level)
+	case AlgorithmZstd:
+		var zopts []zstd.EOption
+		if c.cfg.Level != 0 {
+			zopts = append(zopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.cfg.Level)))
+		}
+
+		w, err = zstd.NewWriter(dst, zopts...)
+	default:
+		return fmt.Errorf("%s: %w", c.cfg.Algorithm, ErrUnknownAlgorithm)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to create compressor: %w", err)
+	}
+
+	if _, err = io.Copy(w, src); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	return nil
+}
+
+type reader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *reader) Close() (err error) {
+	for _, c := range r.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// newReader wraps rc with decompressor of the content encoding.
+// The content is returned as is if it does not start with the magic bytes of the encoding
+func newReader(rc io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+
+	magic, _ := br.Peek(len(zstdMagic))
+
+	switch {
+	case encoding == AlgorithmGzip && bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("failed to read gzip: %w", err)
+		}
+
+		return &reader{Reader: gr, closers: []io.Closer{gr, rc}}, nil
+	case encoding == AlgorithmZstd && bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("failed to read zstd: %w", err)
+		}
+
+		return &reader{Reader: zr, closers: []io.Closer{zr.IOReadCloser(), rc}}, nil
+	}
+
+	return &reader{Reader: br, closers: []io.Closer{rc}}, nil
+}
@@ -3,28 +3,128 @@ package core
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
 type (
 	Storage interface {
-		Store(filePath, path string) (cLink string, err error)
+		Store(filePath, path string, options ...interface{}) (cLink string, err error)
 		GetURL(cLink string, options ...interface{}) (URL string)
 		Remove(cLink string) (err error)
 		GetCLink(path string) (cLink string)
-		StoreByCLink(filePath, cLink string) (err error)
+		StoreByCLink(filePath, cLink string, options ...interface{}) (err error)
 	}
 
 	ExpirationVerifier interface {
 		GetAccessExpireTime(cLink string) (expires time.Time)
 	}
+
+	//Opener - storage that can read stored objects back
+	Opener interface {
+		Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error)
+	}
+
+	//RangeOpener - storage that can read a part of the object without reading it from the start, see OpenRange
+	RangeOpener interface {
+		OpenRange(cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error)
+	}
+
+	//Stater - storage that can describe stored objects
+	Stater interface {
+		Stat(cLink string) (info ObjectInfo, err error)
+	}
+
+	//Lister - storage that can enumerate stored objects by path prefix
+	Lister interface {
+		List(prefix string, fn func(info ObjectInfo) error) (err error)
+	}
+
+	//PathGenerator - creates paths for new files, see package pathgen
+	PathGenerator interface {
+		GeneratePath(fileName string, vars map[string]string) (path string, err error)
+	}
+
+	//Unwrapper - storage that wraps another storage with the same storage key
+	Unwrapper interface {
+		Unwrap() Storage
+	}
+
+	//MetadataUpdater - storage that can change headers and user metadata without re-uploading
+	MetadataUpdater interface {
+		UpdateMetadata(cLink string, options ...interface{}) (err error)
+	}
+
+	//VisibilitySetter - storage that can change access to stored objects, see Visibility
+	VisibilitySetter interface {
+		SetVisibility(cLink string, v Visibility) (err error)
+	}
+
+	//VisibilityGetter - storage that can tell access to stored objects, see Visibility
+	VisibilityGetter interface {
+		GetVisibility(cLink string) (v Visibility, err error)
+	}
+
+	//Restorer - storage in trash mode that can bring back removed objects
+	Restorer interface {
+		Restore(cLink string) (err error)
+	}
+
+	//TrashPurger - storage in trash mode that can remove objects kept in trash longer than retention period
+	TrashPurger interface {
+		PurgeTrash() (purged int, err error)
+	}
+
+	//Versioner - storage keeping previous versions of objects
+	Versioner interface {
+		ListVersions(cLink string) (versions []VersionInfo, err error)
+		RestoreVersion(cLink, versionID string) (err error)
+	}
+
+	//BatchRemover - storage that can remove many objects in few requests.
+	//Results are in the order of cLinks
+	BatchRemover interface {
+		RemoveMany(cLinks []string) (results []DeleteResult)
+		RemovePrefix(prefix string) (results []DeleteResult, err error)
+	}
+
+	//DeleteResult - result of removing one object by RemoveMany or RemovePrefix
+	DeleteResult struct {
+		CLink string
+		Err   error
+	}
+
+	ObjectInfo struct {
+		CLink              string
+		Path               string
+		Size               int64
+		ContentType        string
+		ContentEncoding    string
+		CacheControl       string
+		ContentDisposition string
+		ContentLanguage    string
+		Metadata           map[string]string
+		ETag               string
+		LastModified       time.Time
+		ExpiresAt          time.Time // zero if the object is not temporary
+	}
+
+	//VersionInfo - version of the object, Size and ETag are empty for delete markers
+	VersionInfo struct {
+		ObjectInfo
+		VersionID      string
+		IsLatest       bool
+		IsDeleteMarker bool
+	}
 )
 
 type AbstractStorage struct {
 	defaultStorageKey *string
 	storages          map[string]Storage
+	pathGenerators    map[string]PathGenerator
 }
 
 type (
@@ -46,11 +146,18 @@ var (
 	ErrStorageKeyIsEmpty = errors.New("Storage key is empty")
 	ErrNoDefaultStorage  = errors.New("Default storage not specified")
 	ErrCLinkError        = errors.New("CLink error")
+	ErrNotSupported      = errors.New("Method is not supported by storage")
+	ErrObjectNotFound    = errors.New("Object not found")
+	ErrNoPathGenerator   = errors.New("Path generator not specified")
+	ErrInvalidVisibility = errors.New("Invalid visibility")
+	ErrObjectExists      = errors.New("Object already exists")
+	ErrEmptyPrefix       = errors.New("Prefix is empty")
 )
 
 func New() *AbstractStorage {
 	return &AbstractStorage{
-		storages: make(map[string]Storage),
+		storages:       make(map[string]Storage),
+		pathGenerators: make(map[string]PathGenerator),
 	}
 }
 
@@ -93,22 +200,22 @@ func (aStorage *AbstractStorage) getStorageByCLink(cLink string) (s Storage, err
 }
 
 //CreateCLinkInStorage - save file and create clink in selected storage by storageKey
-func (aStorage *AbstractStorage) CreateCLinkInStorage(filePath, path, storageKey string) (cLink string, err error) {
+func (aStorage *AbstractStorage) CreateCLinkInStorage(filePath, path, storageKey string, options ...interface{}) (cLink string, err error) {
 	s, e := aStorage.getStorage(storageKey)
 	if e != nil {
 		return "", e
 	}
-	return s.Store(filePath, path)
+	return s.Store(filePath, path, options...)
 }
 
 //CreateCLink - save file and create cLink in default storage
-func (aStorage *AbstractStorage) CreateCLink(filePath, path string) (cLink string, err error) {
+func (aStorage *AbstractStorage) CreateCLink(filePath, path string, options ...interface{}) (cLink string, err error) {
 	if aStorage.defaultStorageKey == nil {
 		err = ErrNoDefaultStorage
 		return
 	}
 
-	return aStorage.CreateCLinkInStorage(filePath, path, *aStorage.defaultStorageKey)
+	return aStorage.CreateCLinkInStorage(filePath, path, *aStorage.defaultStorageKey, options...)
 }
 
 
@@ -117,7 +224,10 @@ func (aStorage *AbstractStorage) PrepareCLinkInStorage(path, storageKey string)
 	if e != nil {
 		return "", e
 	}
-	return s.GetCLink(path), nil
+	if cLink = s.GetCLink(path); cLink == "" {
+		return "", ErrCLinkError
+	}
+	return cLink, nil
 }
 
 func (aStorage *AbstractStorage) PrepareCLink(path string) (cLink string, err error) {
@@ -128,6 +238,73 @@ func (aStorage *AbstractStorage) PrepareCLink(path string) (cLink string, err er
 	return aStorage.PrepareCLinkInStorage(path, *aStorage.defaultStorageKey)
 }
 
+//SetPathGenerator - set path generator for CreateCLinkAuto and PrepareCLinkAuto in the storage
+func (aStorage *AbstractStorage) SetPathGenerator(storageKey string, generator PathGenerator) (err error) {
+	if _, err = aStorage.getStorage(storageKey); err != nil {
+		return err
+	}
+	aStorage.pathGenerators[strings.ToLower(storageKey)] = generator
+	return
+}
+
+//CreateCLinkAutoInStorage - save file by generated path and create cLink in selected storage by storageKey,
+//pass FileName option if the name of filePath is not the original one
+func (aStorage *AbstractStorage) CreateCLinkAutoInStorage(filePath, storageKey string, options ...interface{}) (cLink string, err error) {
+	path, err := aStorage.generatePath(filepath.Base(filePath), storageKey, options)
+	if err != nil {
+		return "", err
+	}
+	return aStorage.CreateCLinkInStorage(filePath, path, storageKey, options...)
+}
+
+//CreateCLinkAuto - save file by generated path and create cLink in default storage
+func (aStorage *AbstractStorage) CreateCLinkAuto(filePath string, options ...interface{}) (cLink string, err error) {
+	if aStorage.defaultStorageKey == nil {
+		err = ErrNoDefaultStorage
+		return
+	}
+	return aStorage.CreateCLinkAutoInStorage(filePath, *aStorage.defaultStorageKey, options...)
+}
+
+//PrepareCLinkAutoInStorage - create cLink by generated path without upload, fileName is used for the extension
+func (aStorage *AbstractStorage) PrepareCLinkAutoInStorage(fileName, storageKey string, options ...interface{}) (cLink string, err error) {
+	path, err := aStorage.generatePath(fileName, storageKey, options)
+	if err != nil {
+		return "", err
+	}
+	return aStorage.PrepareCLinkInStorage(path, storageKey)
+}
+
+//PrepareCLinkAuto - create cLink by generated path in default storage without upload
+func (aStorage *AbstractStorage) PrepareCLinkAuto(fileName string, options ...interface{}) (cLink string, err error) {
+	if aStorage.defaultStorageKey == nil {
+		err = ErrNoDefaultStorage
+		return
+	}
+	return aStorage.PrepareCLinkAutoInStorage(fileName, *aStorage.defaultStorageKey, options...)
+}
+
+func (aStorage *AbstractStorage) generatePath(fileName, storageKey string, options []interface{}) (path string, err error) {
+	generator, ok := aStorage.pathGenerators[strings.ToLower(storageKey)]
+	if !ok {
+		return "", ErrNoPathGenerator
+	}
+
+	var vars map[string]string
+	for _, o := range options {
+		switch v := o.(type) {
+		case PathVars:
+			vars = v
+		case FileName:
+			if v != "" {
+				fileName = string(v)
+			}
+		}
+	}
+
+	return generator.GeneratePath(fileName, vars)
+}
+
 //GetURL - return http link by cLink
 func (aStorage *AbstractStorage) GetURL(cLink string, options ...interface{}) (URL string) {
 	s, err := aStorage.getStorageByCLink(cLink)
@@ -146,6 +323,59 @@ func (aStorage *AbstractStorage) Delete(cLink string) (err error) {
 	return s.Remove(cLink)
 }
 
+//DeleteMany - delete files from storages, cLinks of one storage are removed together.
+//Results are in the order of cLinks
+func (aStorage *AbstractStorage) DeleteMany(cLinks []string) (results []DeleteResult) {
+	results = make([]DeleteResult, len(cLinks))
+	groups := make(map[string][]int)
+
+	for i, cLink := range cLinks {
+		results[i].CLink = cLink
+
+		u, e := url.Parse(cLink)
+		if e != nil || u.Scheme == "" {
+			results[i].Err = ErrCLinkError
+			continue
+		}
+
+		key := strings.ToLower(u.Scheme)
+		groups[key] = append(groups[key], i)
+	}
+
+	for key, indexes := range groups {
+		s, e := aStorage.getStorage(key)
+
+		group := make([]string, len(indexes))
+		for j, i := range indexes {
+			group[j] = cLinks[i]
+		}
+
+		var groupResults []DeleteResult
+		if e == nil {
+			groupResults = RemoveMany(s, group)
+		}
+
+		for j, i := range indexes {
+			if e != nil {
+				results[i].Err = e
+				continue
+			}
+			results[i].Err = groupResults[j].Err
+		}
+	}
+
+	return results
+}
+
+//DeletePrefix - delete all files in the storage which path starts with prefix
+func (aStorage *AbstractStorage) DeletePrefix(storageKey, prefix string) (results []DeleteResult, err error) {
+	s, e := aStorage.getStorage(storageKey)
+	if e != nil {
+		return nil, e
+	}
+	return RemovePrefix(s, prefix)
+}
+
 //SetDefaultStorage - set storage as default
 func (aStorage *AbstractStorage) SetDefaultStorage(storageKey string) (err error) {
 	if _, ok := aStorage.storages[storageKey]; !ok {
@@ -155,16 +385,116 @@ func (aStorage *AbstractStorage) SetDefaultStorage(storageKey string) (err error
 	return
 }
 
-func (aStorage *AbstractStorage) UploadByCLink(filePath, cLink string) (err error) {
+func (aStorage *AbstractStorage) UploadByCLink(filePath, cLink string, options ...interface{}) (err error) {
 	s, err := aStorage.getStorageByCLink(cLink)
 	if err != nil {
 		return fmt.Errorf("failed get storage by cLink: %w", err)
 	}
 
-	err = s.StoreByCLink(filePath, cLink)
+	err = s.StoreByCLink(filePath, cLink, options...)
 	return err
 }
 
+//Open - open stored file by cLink for reading
+func (aStorage *AbstractStorage) Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	s, e := aStorage.getStorageByCLink(cLink)
+	if e != nil {
+		return nil, e
+	}
+	return Open(s, cLink, options...)
+}
+
+//OpenRange - open part of stored file by cLink, length < 0 - to the end, see core.OpenRange
+func (aStorage *AbstractStorage) OpenRange(cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error) {
+	s, e := aStorage.getStorageByCLink(cLink)
+	if e != nil {
+		return nil, e
+	}
+	return OpenRange(s, cLink, offset, length, options...)
+}
+
+//Stat - get info about stored file by cLink
+func (aStorage *AbstractStorage) Stat(cLink string) (info ObjectInfo, err error) {
+	s, e := aStorage.getStorageByCLink(cLink)
+	if e != nil {
+		return info, e
+	}
+	return Stat(s, cLink)
+}
+
+//UpdateMetadata - change headers and user metadata of stored file, see core.MergeMetadata
+func (aStorage *AbstractStorage) UpdateMetadata(cLink string, options ...interface{}) (err error) {
+	s, e := aStorage.getStorageByCLink(cLink)
+	if e != nil {
+		return e
+	}
+	return UpdateMetadata(s, cLink, options...)
+}
+
+//SetVisibility - make stored file public or private
+func (aStorage *AbstractStorage) SetVisibility(cLink string, v Visibility) (err error) {
+	s, e := aStorage.getStorageByCLink(cLink)
+	if e != nil {
+		return e
+	}
+	return SetVisibility(s, cLink, v)
+}
+
+//Restore - bring back removed file from trash
+func (aStorage *AbstractStorage) Restore(cLink string) (err error) {
+	s, e := aStorage.getStorageByCLink(cLink)
+	if e != nil {
+		return e
+	}
+	return Restore(s, cLink)
+}
+
+//PurgeTrash - remove files kept in trash of the storage longer than retention period
+func (aStorage *AbstractStorage) PurgeTrash(storageKey string) (purged int, err error) {
+	s, e := aStorage.getStorage(storageKey)
+	if e != nil {
+		return 0, e
+	}
+	return PurgeTrash(s)
+}
+
+//ListVersions - list versions of stored file, the latest first
+func (aStorage *AbstractStorage) ListVersions(cLink string) (versions []VersionInfo, err error) {
+	s, e := aStorage.getStorageByCLink(cLink)
+	if e != nil {
+		return nil, e
+	}
+	return ListVersions(s, cLink)
+}
+
+//RestoreVersion - make the version of stored file the latest one
+func (aStorage *AbstractStorage) RestoreVersion(cLink, versionID string) (err error) {
+	s, e := aStorage.getStorageByCLink(cLink)
+	if e != nil {
+		return e
+	}
+	return RestoreVersion(s, cLink, versionID)
+}
+
+//Close - close all storages, see core.Close
+func (aStorage *AbstractStorage) Close() (err error) {
+	for _, s := range aStorage.storages {
+		if e := Close(s); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//List - call fn for every file in the storage which path starts with prefix
+func (aStorage *AbstractStorage) List(storageKey, prefix string, fn func(info ObjectInfo) error) (err error) {
+	s, e := aStorage.getStorage(storageKey)
+	if e != nil {
+		return e
+	}
+	return List(s, prefix, fn)
+}
+
 func (aStorage *AbstractStorage) GetPathByCLink(cLink string) (path string) {
 	return cLink[strings.LastIndex(cLink, ":")+1:]
 }
\ No newline at end of file
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"io"
+	"strings"
+)
+
+// Open - open object by cLink in storage s.
+// Wrapping storages that do not read objects themselves are unwrapped until
+// a storage implementing Opener is found
+func Open(s Storage, cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	for s != nil {
+		if o, ok := s.(Opener); ok {
+			return o.Open(cLink, options...)
+		}
+		s = unwrap(s)
+	}
+	return nil, ErrNotSupported
+}
+
+// OpenRange - open length bytes of object by cLink in storage s from offset, length < 0 - to the end.
+// Bytes are counted as stored, as Stat describes the object. Wrappers are unwrapped until
+// a storage implementing RangeOpener is found. Only if there is none, the object is read
+// by Open from the start and offset bytes are skipped: Open of the wrappers may change
+// the content, e.g. decompress it
+func OpenRange(s Storage, cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error) {
+	for r := s; r != nil; r = unwrap(r) {
+		if o, ok := r.(RangeOpener); ok {
+			return o.OpenRange(cLink, offset, length, options...)
+		}
+	}
+
+	if rc, err = Open(s, cLink, options...); err != nil {
+		return nil, err
+	}
+
+	if _, err = io.CopyN(io.Discard, rc, offset); err != nil {
+		rc.Close()
+		return nil, err
+	}
+
+	return LimitReadCloser(rc, length), nil
+}
+
+// LimitReadCloser - rc which returns EOF after n bytes, n < 0 - no limit
+func LimitReadCloser(rc io.ReadCloser, n int64) io.ReadCloser {
+	if n < 0 {
+		return rc
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, n), rc}
+}
+
+// Stat - get info about object by cLink in storage s.
+// Storage should return error wrapping ErrObjectNotFound for missing objects
+func Stat(s Storage, cLink string) (info ObjectInfo, err error) {
+	for s != nil {
+		if st, ok := s.(Stater); ok {
+			return st.Stat(cLink)
+		}
+		s = unwrap(s)
+	}
+	return info, ErrNotSupported
+}
+
+// List - call fn for every object in storage s which path starts with prefix.
+// Listing stops on the first error returned by fn
+func List(s Storage, prefix string, fn func(info ObjectInfo) error) (err error) {
+	for s != nil {
+		if l, ok := s.(Lister); ok {
+			return l.List(prefix, fn)
+		}
+		s = unwrap(s)
+	}
+	return ErrNotSupported
+}
+
+// UpdateMetadata - change headers and user metadata of object by cLink in storage s
+func UpdateMetadata(s Storage, cLink string, options ...interface{}) (err error) {
+	for s != nil {
+		if u, ok := s.(MetadataUpdater); ok {
+			return u.UpdateMetadata(cLink, options...)
+		}
+		s = unwrap(s)
+	}
+	return ErrNotSupported
+}
+
+// SetVisibility - make object by cLink in storage s public or private
+func SetVisibility(s Storage, cLink string, v Visibility) (err error) {
+	for s != nil {
+		if vs, ok := s.(VisibilitySetter); ok {
+			return vs.SetVisibility(cLink, v)
+		}
+		s = unwrap(s)
+	}
+	return ErrNotSupported
+}
+
+// GetVisibility - get access to object by cLink in storage s: public or private
+func GetVisibility(s Storage, cLink string) (v Visibility, err error) {
+	for s != nil {
+		if vg, ok := s.(VisibilityGetter); ok {
+			return vg.GetVisibility(cLink)
+		}
+		s = unwrap(s)
+	}
+	return "", ErrNotSupported
+}
+
+// Restore - bring back removed object by cLink from trash of storage s.
+// Storage returns error wrapping ErrObjectNotFound if the object is not in trash
+// and ErrObjectExists if a new object is stored by the same path
+func Restore(s Storage, cLink string) (err error) {
+	for s != nil {
+		if r, ok := s.(Restorer); ok {
+			return r.Restore(cLink)
+		}
+		s = unwrap(s)
+	}
+	return ErrNotSupported
+}
+
+// PurgeTrash - remove objects kept in trash of storage s longer than retention period
+func PurgeTrash(s Storage) (purged int, err error) {
+	for s != nil {
+		if p, ok := s.(TrashPurger); ok {
+			return p.PurgeTrash()
+		}
+		s = unwrap(s)
+	}
+	return 0, ErrNotSupported
+}
+
+// ListVersions - list versions of object by cLink in storage s, the latest first
+func ListVersions(s Storage, cLink string) (versions []VersionInfo, err error) {
+	for s != nil {
+		if v, ok := s.(Versioner); ok {
+			return v.ListVersions(cLink)
+		}
+		s = unwrap(s)
+	}
+	return nil, ErrNotSupported
+}
+
+// RestoreVersion - copy the version of object by cLink in storage s over the latest one,
+// the restored copy becomes a new version
+func RestoreVersion(s Storage, cLink, versionID string) (err error) {
+	for s != nil {
+		if v, ok := s.(Versioner); ok {
+			return v.RestoreVersion(cLink, versionID)
+		}
+		s = unwrap(s)
+	}
+	return ErrNotSupported
+}
+
+// RemoveMany - remove objects by cLinks from storage s, results are in the order of cLinks.
+// Storages without BatchRemover remove objects one by one. Wrappers are not unwrapped,
+// so their Remove is not skipped
+func RemoveMany(s Storage, cLinks []string) (results []DeleteResult) {
+	if b, ok := s.(BatchRemover); ok {
+		return b.RemoveMany(cLinks)
+	}
+
+	results = make([]DeleteResult, len(cLinks))
+	for i, cLink := range cLinks {
+		results[i] = DeleteResult{CLink: cLink, Err: s.Remove(cLink)}
+	}
+	return results
+}
+
+// RemovePrefix - remove all objects in storage s which path starts with prefix.
+// Storages without BatchRemover should implement Lister, objects are removed one by one
+func RemovePrefix(s Storage, prefix string) (results []DeleteResult, err error) {
+	if strings.Trim(prefix, "/") == "" {
+		return nil, ErrEmptyPrefix
+	}
+
+	if b, ok := s.(BatchRemover); ok {
+		return b.RemovePrefix(prefix)
+	}
+
+	var cLinks []string
+
+	err = List(s, prefix, func(info ObjectInfo) error {
+		cLinks = append(cLinks, s.GetCLink(info.Path))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return RemoveMany(s, cLinks), nil
+}
+
+// Close - close storage s and all storages wrapped by it which implement io.Closer,
+// e.g. stop background goroutines. The first error is returned
+func Close(s Storage) (err error) {
+	for s != nil {
+		if c, ok := s.(io.Closer); ok {
+			if e := c.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+		s = unwrap(s)
+	}
+	return err
+}
+
+func unwrap(s Storage) Storage {
+	if u, ok := s.(Unwrapper); ok {
+		return u.Unwrap()
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package core
+
+import (
+	"strings"
+	"time"
+)
+
+type (
+	//ContentType - Store option, overrides the content type detected by file content
+	ContentType string
+
+	//ContentEncoding - Store option, saved as Content-Encoding of the object
+	ContentEncoding string
+
+	//CacheControl - Store option, saved as Cache-Control of the object
+	CacheControl string
+
+	//ContentDisposition - Store option, saved as Content-Disposition of the object
+	ContentDisposition string
+
+	//ContentLanguage - Store option, saved as Content-Language of the object
+	ContentLanguage string
+
+	//UserMetadata - Store option, arbitrary metadata of the object (x-amz-meta-* in s3 and yos).
+	//Keys are case-insensitive and returned in lower case by Stat
+	UserMetadata map[string]string
+
+	//Visibility - Store and GetURL option, access to the object by unsigned URL.
+	//Objects stored without it get the default access of the bucket
+	Visibility string
+
+	//ExpiresAt - Store option, the object is removed automatically after this time.
+	//s3 and yos remove objects by bucket lifecycle rules with day precision
+	ExpiresAt time.Time
+
+	//VersionID - GetURL and Open option, see WithVersion
+	VersionID string
+
+	//PathVars - option of CreateCLinkAuto and PrepareCLinkAuto, variables for the path template
+	PathVars map[string]string
+
+	//FileName - option of CreateCLinkAuto, original name of the file for {name} and {ext} of the path template,
+	//default - the name of the stored file, e.g. of the temp file of the upload
+	FileName string
+
+	//StoreOptions - all Store options in one place, see ParseStoreOptions
+	StoreOptions struct {
+		ContentType        string
+		ContentEncoding    string
+		CacheControl       string
+		ContentDisposition string
+		ContentLanguage    string
+		UserMetadata       map[string]string
+		Visibility         Visibility
+		ExpiresAt          time.Time
+	}
+)
+
+const (
+	VisibilityPublic  Visibility = "public"
+	VisibilityPrivate Visibility = "private"
+)
+
+// WithVersion - GetURL and Open option, address the version of the object instead of the latest one
+func WithVersion(id string) VersionID {
+	return VersionID(id)
+}
+
+// ParseVersion returns version ID from options or empty string if it is not set
+func ParseVersion(options ...interface{}) string {
+	for _, option := range options {
+		if v, ok := option.(VersionID); ok {
+			return string(v)
+		}
+	}
+	return ""
+}
+
+// ParseVisibility returns Visibility from options or empty value if it is not set
+func ParseVisibility(options ...interface{}) (v Visibility) {
+	for _, option := range options {
+		if o, ok := option.(Visibility); ok {
+			v = o
+		}
+	}
+	return
+}
+
+// ParseStoreOptions - collect known Store options, unknown options are ignored
+func ParseStoreOptions(options ...interface{}) (o StoreOptions) {
+	for _, option := range options {
+		switch v := option.(type) {
+		case ContentType:
+			o.ContentType = string(v)
+		case ContentEncoding:
+			o.ContentEncoding = string(v)
+		case CacheControl:
+			o.CacheControl = string(v)
+		case ContentDisposition:
+			o.ContentDisposition = string(v)
+		case ContentLanguage:
+			o.ContentLanguage = string(v)
+		case UserMetadata:
+			o.UserMetadata = addUserMetadata(o.UserMetadata, v)
+		case Visibility:
+			o.Visibility = v
+		case ExpiresAt:
+			o.ExpiresAt = time.Time(v)
+		}
+	}
+	return
+}
+
+// MergeMetadata - metadata of the object with changes from the options.
+// Headers are replaced by not empty options, user metadata is merged by keys
+// and the key with empty value is removed
+func MergeMetadata(info ObjectInfo, options ...interface{}) (o StoreOptions) {
+	changes := ParseStoreOptions(options...)
+
+	o = StoreOptions{
+		ContentType:        info.ContentType,
+		ContentEncoding:    info.ContentEncoding,
+		CacheControl:       info.CacheControl,
+		ContentDisposition: info.ContentDisposition,
+		ContentLanguage:    info.ContentLanguage,
+		UserMetadata:       mergeUserMetadata(nil, info.Metadata),
+	}
+
+	if changes.ContentType != "" {
+		o.ContentType = changes.ContentType
+	}
+	if changes.ContentEncoding != "" {
+		o.ContentEncoding = changes.ContentEncoding
+	}
+	if changes.CacheControl != "" {
+		o.CacheControl = changes.CacheControl
+	}
+	if changes.ContentDisposition != "" {
+		o.ContentDisposition = changes.ContentDisposition
+	}
+	if changes.ContentLanguage != "" {
+		o.ContentLanguage = changes.ContentLanguage
+	}
+
+	o.UserMetadata = mergeUserMetadata(o.UserMetadata, changes.UserMetadata)
+
+	return o
+}
+
+// MetadataOptions - Store options with headers and user metadata of the object,
+// pass them to Store to copy the object with its metadata
+func MetadataOptions(info ObjectInfo) (options []interface{}) {
+	o := MergeMetadata(info)
+
+	if o.ContentType != "" {
+		options = append(options, ContentType(o.ContentType))
+	}
+	if o.ContentEncoding != "" {
+		options = append(options, ContentEncoding(o.ContentEncoding))
+	}
+	if o.CacheControl != "" {
+		options = append(options, CacheControl(o.CacheControl))
+	}
+	if o.ContentDisposition != "" {
+		options = append(options, ContentDisposition(o.ContentDisposition))
+	}
+	if o.ContentLanguage != "" {
+		options = append(options, ContentLanguage(o.ContentLanguage))
+	}
+	if len(o.UserMetadata) > 0 {
+		options = append(options, UserMetadata(o.UserMetadata))
+	}
+
+	return options
+}
+
+// addUserMetadata copies src to dst with lower case keys, empty values are kept for MergeMetadata
+func addUserMetadata(dst, src map[string]string) map[string]string {
+	for k, v := range src {
+		if dst == nil {
+			dst = make(map[string]string)
+		}
+
+		dst[strings.ToLower(k)] = v
+	}
+	return dst
+}
+
+func mergeUserMetadata(dst, src map[string]string) map[string]string {
+	for k, v := range src {
+		if dst == nil {
+			dst = make(map[string]string)
+		}
+
+		k = strings.ToLower(k)
+
+		if v == "" {
+			delete(dst, k)
+			continue
+		}
+
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,121 @@
+package gc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	// References - iterator over live cLinks, e.g. rows of the database.
+	// It calls fn for every cLink and stops on the first error of fn
+	References func(fn func(cLink string) error) error
+
+	Config struct {
+		StorageKey string
+		StorageCtl core.Storage // should implement core.Lister
+		Prefix     string       // only objects which path starts with Prefix are checked
+
+		// objects modified within GracePeriod are kept even if nothing references them yet,
+		// e.g. uploaded while the references were read. Default DefaultGracePeriod
+		GracePeriod time.Duration
+
+		// only report orphans, nothing is removed
+		DryRun bool
+
+		// DerivedFrom returns possible paths of the objects the object at path is derived from,
+		// e.g. imaging.ImagingStorage.DerivedFrom for image variants. Derived objects are kept
+		// while any of them is referenced. Nil - objects are not derived
+		DerivedFrom func(path string) (origins []string)
+	}
+
+	Report struct {
+		Scanned    int // listed objects
+		Referenced int // listed objects found in references
+		Derived    int // listed objects derived from the referenced ones
+		Recent     int // not referenced objects kept by GracePeriod
+
+		Orphans []core.ObjectInfo   // not referenced objects older than GracePeriod
+		Deleted int                 // removed orphans, always 0 in DryRun
+		Failed  []core.DeleteResult // orphans failed to remove
+	}
+)
+
+const DefaultGracePeriod = 24 * time.Hour
+
+// Collect lists the storage, compares objects with references and removes orphans.
+// All references are kept in memory while the storage is listed
+func Collect(cfg *Config, refs References) (report Report, err error) {
+	s := cfg.StorageCtl
+
+	live := make(map[string]bool)
+
+	err = refs(func(cLink string) error {
+		if path := common.CLinkToPath(cfg.StorageKey, cLink); path != "" {
+			live[s.GetCLink(path)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to read references: %w", err)
+	}
+
+	gracePeriod := cfg.GracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultGracePeriod
+	}
+
+	deadline := time.Now().Add(-gracePeriod)
+
+	err = core.List(s, cfg.Prefix, func(info core.ObjectInfo) error {
+		report.Scanned++
+
+		switch {
+		case live[s.GetCLink(info.Path)]:
+			report.Referenced++
+		case cfg.DerivedFrom != nil && anyLive(s, live, cfg.DerivedFrom(info.Path)):
+			report.Derived++
+		case info.LastModified.After(deadline):
+			report.Recent++
+		default:
+			report.Orphans = append(report.Orphans, info)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return report, err // nolint:wrapcheck
+	}
+
+	if cfg.DryRun || len(report.Orphans) == 0 {
+		return report, nil
+	}
+
+	cLinks := make([]string, len(report.Orphans))
+	for i, info := range report.Orphans {
+		cLinks[i] = s.GetCLink(info.Path)
+	}
+
+	for _, r := range core.RemoveMany(s, cLinks) {
+		if r.Err != nil {
+			report.Failed = append(report.Failed, r)
+			continue
+		}
+
+		report.Deleted++
+	}
+
+	return report, nil
+}
+
+func anyLive(s core.Storage, live map[string]bool, paths []string) bool {
+	for _, p := range paths {
+		if live[s.GetCLink(p)] {
+			return true
+		}
+	}
+
+	return false
+}
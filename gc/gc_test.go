@@ -0,0 +1,143 @@
+package gc
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/imaging"
+	"github.com/rosberry/storage/local"
+)
+
+var testStorageKey = "gcFile"
+
+func TestCollect(t *testing.T) {
+	root := t.TempDir()
+	s := local.New(&local.Config{
+		StorageKey: testStorageKey,
+		Root:       root,
+		BufferSize: 32 * 1024,
+	})
+
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, []byte("gc"), 0o644)
+
+	live, _ := s.Store(tmp, "/uploads/live.txt")
+	orphan, _ := s.Store(tmp, "/uploads/orphan.txt")
+	recent, _ := s.Store(tmp, "/uploads/recent.txt")
+	other, _ := s.Store(tmp, "/other/orphan.txt")
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, p := range []string{"uploads/live.txt", "uploads/orphan.txt", "other/orphan.txt"} {
+		os.Chtimes(filepath.Join(root, p), old, old)
+	}
+
+	refs := func(fn func(cLink string) error) error {
+		for _, cLink := range []string{live, "otherKey:uploads/orphan.txt"} {
+			if err := fn(cLink); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cfg := &Config{
+		StorageKey: testStorageKey,
+		StorageCtl: s,
+		Prefix:     "uploads/",
+		DryRun:     true,
+	}
+
+	report, err := Collect(cfg, refs)
+	if err != nil {
+		t.Fatalf("Collect err: %q", err)
+	}
+
+	if report.Scanned != 3 || report.Referenced != 1 || report.Recent != 1 ||
+		len(report.Orphans) != 1 || report.Orphans[0].CLink != orphan || report.Deleted != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	if _, err = s.Stat(orphan); err != nil {
+		t.Errorf("orphan is removed in dry run: %v", err)
+	}
+
+	cfg.DryRun = false
+
+	if report, _ = Collect(cfg, refs); report.Deleted != 1 || len(report.Failed) != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	for cLink, exists := range map[string]bool{live: true, orphan: false, recent: true, other: true} {
+		if _, err = s.Stat(cLink); (err == nil) != exists {
+			t.Errorf("%s: unexpected Stat err: %v", cLink, err)
+		}
+	}
+
+	// objects are kept while the grace period lasts
+	cfg.GracePeriod = 72 * time.Hour
+	cfg.Prefix = ""
+
+	if report, _ = Collect(cfg, refs); report.Deleted != 0 || report.Recent != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	if _, err = core.Stat(s, other); err != nil {
+		t.Errorf("Stat err: %q", err)
+	}
+}
+
+func TestCollectDerived(t *testing.T) {
+	root := t.TempDir()
+	s := imaging.New(&imaging.Config{
+		StorageKey: testStorageKey,
+		StorageCtl: local.New(&local.Config{
+			StorageKey: testStorageKey,
+			Root:       root,
+			BufferSize: 32 * 1024,
+		}),
+		Variants: []imaging.Variant{
+			{Name: "thumb", Width: 2, Height: 2, Mode: imaging.ModeFill, Format: imaging.FormatJPEG},
+			{Name: "medium", Width: 4},
+		},
+	})
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8))) // nolint:errcheck
+
+	tmp := filepath.Join(t.TempDir(), "image")
+	ioutil.WriteFile(tmp, buf.Bytes(), 0o644)
+
+	live, _ := s.Store(tmp, "photos/cat.png")
+	s.Store(tmp, "photos/dog.png") // nolint:errcheck
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"cat", "dog"} {
+		for _, p := range []string{".png", ".thumb.jpg", ".medium.png"} {
+			os.Chtimes(filepath.Join(root, "photos", name+p), old, old)
+		}
+	}
+
+	refs := func(fn func(cLink string) error) error {
+		return fn(live)
+	}
+
+	report, err := Collect(&Config{StorageKey: testStorageKey, StorageCtl: s, DerivedFrom: s.DerivedFrom}, refs)
+	if err != nil || report.Referenced != 1 || report.Derived != 2 || report.Deleted != 3 {
+		t.Errorf("unexpected report: %+v, err %v", report, err)
+	}
+
+	for p, exists := range map[string]bool{
+		"photos/cat.thumb.jpg": true, "photos/cat.medium.png": true, "photos/dog.thumb.jpg": false,
+	} {
+		if _, err = os.Stat(filepath.Join(root, p)); (err == nil) != exists {
+			t.Errorf("%s: unexpected Stat err: %v", p, err)
+		}
+	}
+}
@@ -4,17 +4,18 @@ go 1.17
 
 require (
 	github.com/aws/aws-sdk-go v1.42.44
+	github.com/google/uuid v1.1.1
 	github.com/jinzhu/configor v1.2.1
+	github.com/klauspost/compress v1.13.5
 	github.com/minio/minio-go/v7 v7.0.21
+	golang.org/x/text v0.3.6
 )
 
 require (
 	github.com/BurntSushi/toml v0.3.1 // indirect
 	github.com/dustin/go-humanize v1.0.0 // indirect
-	github.com/google/uuid v1.1.1 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/json-iterator/go v1.1.10 // indirect
-	github.com/klauspost/compress v1.13.5 // indirect
 	github.com/klauspost/cpuid v1.3.1 // indirect
 	github.com/minio/md5-simd v1.1.0 // indirect
 	github.com/minio/sha256-simd v0.1.1 // indirect
@@ -26,7 +27,6 @@ require (
 	golang.org/x/crypto v0.0.0-20201216223049-8b5274cf687f // indirect
 	golang.org/x/net v0.0.0-20211216030914-fe4d6282115f // indirect
 	golang.org/x/sys v0.0.0-20210423082822-04245dca01da // indirect
-	golang.org/x/text v0.3.6 // indirect
 	gopkg.in/ini.v1 v1.57.0 // indirect
 	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
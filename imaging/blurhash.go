@@ -0,0 +1,122 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// blurHash encodes the image by BlurHash algorithm with x*y components, 1-9 each.
+// See https://github.com/woltapp/blurhash
+func blurHash(img *image.RGBA, x, y int) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return ""
+	}
+
+	// linear values of the pixels are used by every component
+	linear := make([][3]float64, w*h)
+
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			i := py*img.Stride + px*4
+			linear[py*w+px] = [3]float64{
+				sRGBToLinear(img.Pix[i]), sRGBToLinear(img.Pix[i+1]), sRGBToLinear(img.Pix[i+2]),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, x*y)
+
+	for j := 0; j < y; j++ {
+		for i := 0; i < x; i++ {
+			var f [3]float64
+
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+
+			for py := 0; py < h; py++ {
+				for px := 0; px < w; px++ {
+					basis := math.Cos(math.Pi*float64(i*px)/float64(w)) * math.Cos(math.Pi*float64(j*py)/float64(h))
+					c := linear[py*w+px]
+
+					f[0] += basis * c[0]
+					f[1] += basis * c[1]
+					f[2] += basis * c[2]
+				}
+			}
+
+			scale := norm / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var b strings.Builder
+
+	encode83(&b, (x-1)+(y-1)*9, 1)
+
+	maxValue := 1.0
+
+	if ac := factors[1:]; len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+
+		encode83(&b, quantisedMax, 1)
+	} else {
+		encode83(&b, 0, 1)
+	}
+
+	dc := factors[0]
+	encode83(&b, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+
+	for _, f := range factors[1:] {
+		encode83(&b, quantAC(f[0], maxValue)*19*19+quantAC(f[1], maxValue)*19+quantAC(f[2], maxValue), 2)
+	}
+
+	return b.String()
+}
+
+func encode83(b *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value
+		for k := 0; k < length-i; k++ {
+			digit /= 83
+		}
+
+		b.WriteByte(base83[digit%83])
+	}
+}
+
+func quantAC(v, maxValue float64) int {
+	v /= maxValue
+	signPow := math.Copysign(math.Sqrt(math.Abs(v)), v)
+
+	return int(math.Max(0, math.Min(18, math.Floor(signPow*9+9.5))))
+}
+
+func sRGBToLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
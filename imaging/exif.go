@@ -0,0 +1,154 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+)
+
+const (
+	markerSOI  = 0xd8
+	markerAPP1 = 0xe1
+	markerSOS  = 0xda
+
+	tagOrientation = 0x0112
+	tagGPSInfo     = 0x8825
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// readExif returns the TIFF part of the Exif segment of JPEG, nil if there is none
+func readExif(r io.Reader) []byte {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil || head[0] != 0xff || head[1] != markerSOI {
+		return nil
+	}
+
+	for {
+		var m [4]byte
+		if _, err := io.ReadFull(r, m[:]); err != nil || m[0] != 0xff {
+			return nil
+		}
+
+		// the image data starts, metadata segments are before it
+		if m[1] == markerSOS {
+			return nil
+		}
+
+		size := int(binary.BigEndian.Uint16(m[2:])) - 2
+		if size < 0 {
+			return nil
+		}
+
+		segment := make([]byte, size)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil
+		}
+
+		if m[1] == markerAPP1 && bytes.HasPrefix(segment, exifHeader) {
+			return segment[len(exifHeader):]
+		}
+	}
+}
+
+// orientation returns Orientation tag of IFD0 of the TIFF data, 1 - normal or unknown
+func orientation(tiff []byte) int {
+	if v, ok := ifd0Tag(tiff, tagOrientation); ok && v >= 1 && v <= 8 {
+		return int(v)
+	}
+
+	return 1
+}
+
+// ifd0Tag returns the value of SHORT or LONG tag of IFD0 of the TIFF data
+func ifd0Tag(tiff []byte, tag uint16) (uint32, bool) {
+	if len(tiff) < 8 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 0 || offset+2 > len(tiff) {
+		return 0, false
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+
+		if order.Uint16(tiff[entry:]) != tag {
+			continue
+		}
+
+		// type 3 - SHORT, 4 - LONG, the value is in the entry
+		switch order.Uint16(tiff[entry+2:]) {
+		case 3:
+			return uint32(order.Uint16(tiff[entry+8:])), true
+		case 4:
+			return order.Uint32(tiff[entry+8:]), true
+		default:
+			return 0, false
+		}
+	}
+
+	return 0, false
+}
+
+// orient transforms img by Exif orientation, so it is displayed upright without the tag
+func orient(img *image.RGBA, o int) *image.RGBA {
+	if o <= 1 || o > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// orientations 5-8 swap the sides
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+
+			switch o {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counterclockwise
+				dx, dy = y, w-1-x
+			}
+
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], img.Pix[y*img.Stride+x*4:y*img.Stride+x*4+4])
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,358 @@
+package imaging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/proxy"
+)
+
+type (
+	HandlerConfig struct {
+		Storage *core.AbstractStorage
+
+		// CacheStorageKey - instance of Storage for the resized images, it must support Stat
+		CacheStorageKey string
+
+		// SigningKey - HMAC key of the request parameters, requests are refused without it
+		SigningKey string
+
+		// MaxWidth and MaxHeight of the result, default DefaultMaxSize
+		MaxWidth  int
+		MaxHeight int
+
+		// MaxPixels of the original, larger images are not decoded, default DefaultMaxPixels
+		MaxPixels int
+
+		// Quality of JPEG, default DefaultQuality
+		Quality int
+
+		// responds with 302 to GetURL of the resized image instead of streaming it
+		Redirect bool
+
+		// Options returns GetURL options of the resized image in Redirect mode
+		Options func(r *http.Request, cLink string) []interface{}
+
+		TempDir string
+	}
+
+	// Handler - resizes images on demand, see NewHandler
+	Handler struct {
+		cfg   HandlerConfig
+		serve http.Handler
+
+		mu       sync.Mutex
+		inflight map[string]*resizeCall
+	}
+
+	// resizeCall - resize shared by concurrent requests of the same image
+	resizeCall struct {
+		wg  sync.WaitGroup
+		err error
+	}
+
+	resizedKey struct{}
+)
+
+const (
+	DefaultMaxSize = 4096
+
+	widthQuery     = "w"
+	heightQuery    = "h"
+	modeQuery      = "fit"
+	formatQuery    = "fm"
+	signatureQuery = "s"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidParams    = errors.New("invalid resize parameters")
+)
+
+// NewHandler returns handler of GET and HEAD requests "/<storage key>/<path>?w=&h=&fit=&fm=&s=",
+// see Handler.Path. The resized image is stored in CacheStorageKey on the first request
+// and served from there later
+func NewHandler(cfg *HandlerConfig) *Handler {
+	h := &Handler{
+		cfg:      *cfg,
+		inflight: make(map[string]*resizeCall),
+	}
+
+	if h.cfg.MaxWidth <= 0 {
+		h.cfg.MaxWidth = DefaultMaxSize
+	}
+
+	if h.cfg.MaxHeight <= 0 {
+		h.cfg.MaxHeight = DefaultMaxSize
+	}
+
+	if h.cfg.MaxPixels <= 0 {
+		h.cfg.MaxPixels = DefaultMaxPixels
+	}
+
+	if h.cfg.Quality <= 0 {
+		h.cfg.Quality = DefaultQuality
+	}
+
+	h.serve = proxy.New(&proxy.Config{
+		Storage: cfg.Storage,
+		CLink: func(r *http.Request) (string, error) {
+			return r.Context().Value(resizedKey{}).(string), nil
+		},
+		Options:  cfg.Options,
+		Redirect: cfg.Redirect,
+	})
+
+	return h
+}
+
+// Path returns signed path of the resized image, Name of the variant is ignored.
+// Mount the handler with http.StripPrefix and prepend the prefix to the path
+func (h *Handler) Path(cLink string, v Variant) string {
+	key := cLink[:strings.Index(cLink, ":")+1]
+
+	q := url.Values{}
+	q.Set(widthQuery, strconv.Itoa(v.Width))
+	q.Set(heightQuery, strconv.Itoa(v.Height))
+
+	if v.Mode != "" {
+		q.Set(modeQuery, string(v.Mode))
+	}
+
+	if v.Format != "" {
+		q.Set(formatQuery, v.Format)
+	}
+
+	q.Set(signatureQuery, h.signature(cLink, q))
+
+	segments := strings.Split(strings.TrimSuffix(key, ":")+"/"+cLink[len(key):], "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return "/" + strings.Join(segments, "/") + "?" + q.Encode()
+}
+
+// Purge removes all resized images of the original, call it when the original is replaced or removed
+func (h *Handler) Purge(cLink string) (err error) {
+	_, err = h.cfg.Storage.DeletePrefix(h.cfg.CacheStorageKey, resizedPrefix(cLink))
+	return err // nolint:wrapcheck
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	cLink, err := proxy.PathCLink(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+
+	if err = h.verify(cLink, q); err != nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	v, err := h.parse(cLink, q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resized, err := h.resized(cLink, v)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+
+	h.serve.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), resizedKey{}, resized)))
+}
+
+func (h *Handler) verify(cLink string, q url.Values) error {
+	if h.cfg.SigningKey == "" {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(h.signature(cLink, q)), []byte(q.Get(signatureQuery))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// signature - HMAC-SHA256 of the cLink and the resize parameters
+func (h *Handler) signature(cLink string, q url.Values) string {
+	mac := hmac.New(sha256.New, []byte(h.cfg.SigningKey))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", cLink,
+		q.Get(widthQuery), q.Get(heightQuery), q.Get(modeQuery), q.Get(formatQuery))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parse returns the variant with defaults of the empty parameters
+func (h *Handler) parse(cLink string, q url.Values) (v Variant, err error) {
+	v.Width, _ = strconv.Atoi(q.Get(widthQuery))
+	v.Height, _ = strconv.Atoi(q.Get(heightQuery))
+
+	if v.Width < 0 || v.Height < 0 || v.Width+v.Height == 0 || v.Width > h.cfg.MaxWidth || v.Height > h.cfg.MaxHeight {
+		return v, fmt.Errorf("%w: size %dx%d", ErrInvalidParams, v.Width, v.Height)
+	}
+
+	switch Mode(q.Get(modeQuery)) {
+	case "", ModeFit:
+		v.Mode = ModeFit
+	case ModeFill:
+		v.Mode = ModeFill
+	default:
+		return v, fmt.Errorf("%w: %s", ErrInvalidParams, q.Get(modeQuery))
+	}
+
+	switch v.Format = q.Get(formatQuery); v.Format {
+	case FormatJPEG, FormatPNG:
+	case "":
+		v.Format = variantFormat(v, path.Ext(cLink))
+	default:
+		return v, fmt.Errorf("%w: %s", ErrInvalidParams, v.Format)
+	}
+
+	return v, nil
+}
+
+// resized returns cLink of the resized image, the image is made if it is not cached yet.
+// Concurrent requests of the same image wait for the first one
+func (h *Handler) resized(cLink string, v Variant) (string, error) {
+	cache, err := h.cfg.Storage.GetStorage(h.cfg.CacheStorageKey)
+	if err != nil {
+		return "", err // nolint:wrapcheck
+	}
+
+	p := resizedPrefix(cLink) + fmt.Sprintf("%dx%d-%s.%s", v.Width, v.Height, v.Mode, v.Format)
+	resized := cache.GetCLink(p)
+
+	h.mu.Lock()
+	if call, ok := h.inflight[resized]; ok {
+		h.mu.Unlock()
+		call.wg.Wait()
+
+		if call.err != nil {
+			return "", call.err
+		}
+
+		return resized, nil
+	}
+
+	call := &resizeCall{}
+	call.wg.Add(1)
+	h.inflight[resized] = call
+	h.mu.Unlock()
+
+	call.err = h.storeResized(cache, cLink, resized, p, v)
+
+	h.mu.Lock()
+	delete(h.inflight, resized)
+	h.mu.Unlock()
+
+	call.wg.Done()
+
+	if call.err != nil {
+		return "", call.err
+	}
+
+	return resized, nil
+}
+
+// storeResized resizes the original to the cache storage if it is not there yet
+func (h *Handler) storeResized(cache core.Storage, cLink, resized, p string, v Variant) error {
+	_, err := h.cfg.Storage.Stat(resized)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, core.ErrObjectNotFound) {
+		return err // nolint:wrapcheck
+	}
+
+	tmp, err := h.resize(cLink, v)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if _, err = cache.Store(tmp, p, core.ContentType("image/"+v.Format)); err != nil {
+		return fmt.Errorf("failed to store resized image: %w", err)
+	}
+
+	return nil
+}
+
+// resize downloads the original and writes the resized image to the temp file, the caller removes it
+func (h *Handler) resize(cLink string, v Variant) (string, error) {
+	rc, err := h.cfg.Storage.Open(cLink)
+	if err != nil {
+		return "", err // nolint:wrapcheck
+	}
+	defer rc.Close()
+
+	f, err := os.CreateTemp(h.cfg.TempDir, "imaging-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err = io.Copy(f, rc); err != nil {
+		return "", fmt.Errorf("failed to download original: %w", err)
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	src, err := decode(f, h.cfg.MaxPixels)
+	if err != nil {
+		return "", err
+	}
+
+	return encode(resize(src, v), v.Format, h.cfg.Quality, h.cfg.TempDir)
+}
+
+// resizedPrefix - directory of the resized images of the original in the cache storage
+func resizedPrefix(cLink string) string {
+	return strings.Replace(cLink, ":", "/", 1) + "/"
+}
+
+func httpError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, core.ErrObjectNotFound) || errors.Is(err, os.ErrNotExist) ||
+		errors.Is(err, core.ErrStorageNotFound) || errors.Is(err, core.ErrCLinkError):
+		http.NotFound(w, r)
+	case errors.Is(err, ErrInvalidImage):
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+	case errors.Is(err, ErrTooManyPixels):
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+	default:
+		log.Println("Failed to resize image:", r.URL.Path, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+	}
+}
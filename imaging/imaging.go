@@ -0,0 +1,563 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // nolint:golint
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	Config struct {
+		StorageKey   string
+		StorageCtl   core.Storage
+		Variants     []Variant
+		ContentTypes []string // detected by content, default DefaultContentTypes
+		Quality      int      // JPEG quality of variants, default DefaultQuality
+		MaxPixels    int      // larger images are refused before decoding, default DefaultMaxPixels
+		TempDir      string
+
+		// Sanitize removes metadata of JPEG and PNG originals before upload, see imaging.Sanitize
+		Sanitize bool
+
+		// OnSanitize is called with the report of every sanitized original after it is stored
+		OnSanitize func(cLink string, report SanitizeReport)
+
+		// ExtractMetadata saves ImageInfo of the originals as their user metadata, see ImagingStorage.ImageInfo
+		ExtractMetadata bool
+	}
+
+	// Variant - derived image stored next to the original, see VariantPath
+	Variant struct {
+		Name   string
+		Width  int    // 0 - not limited in ModeFit
+		Height int    // 0 - not limited in ModeFit
+		Mode   Mode   // default ModeFit
+		Format string // FormatJPEG or FormatPNG, empty - JPEG for JPEG originals and PNG for others
+	}
+
+	Mode string
+
+	ImagingStorage struct {
+		cfg Config
+	}
+)
+
+const (
+	// ModeFit scales the image down to fit into Width x Height keeping the aspect ratio
+	ModeFit Mode = "fit"
+	// ModeFill scales and crops the image to exactly Width x Height
+	ModeFill Mode = "fill"
+
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+
+	DefaultQuality   = 85
+	DefaultMaxPixels = 50 * 1000 * 1000
+)
+
+var (
+	DefaultContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+	ErrInvalidImage   = errors.New("invalid image")
+	ErrTooManyPixels  = errors.New("image is too large")
+	ErrUnknownVariant = errors.New("unknown image variant")
+	ErrInvalidVariant = errors.New("invalid image variant")
+)
+
+func New(cfg *Config) *ImagingStorage {
+	s := &ImagingStorage{
+		cfg: *cfg,
+	}
+
+	if len(s.cfg.ContentTypes) == 0 {
+		s.cfg.ContentTypes = DefaultContentTypes
+	}
+
+	if s.cfg.Quality <= 0 {
+		s.cfg.Quality = DefaultQuality
+	}
+
+	if s.cfg.MaxPixels <= 0 {
+		s.cfg.MaxPixels = DefaultMaxPixels
+	}
+
+	return s
+}
+
+// ParseVariants parses variants of the instance config: "name:WxH[:fit|fill][:jpeg|png]"
+// separated by commas, e.g. "thumb:200x200:fill:jpeg, medium:800x0"
+func ParseVariants(s string) (variants []Variant, err error) {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 4 || parts[0] == "" {
+			return nil, fmt.Errorf("%s: %w", item, ErrInvalidVariant)
+		}
+
+		v := Variant{Name: parts[0]}
+
+		size := strings.SplitN(parts[1], "x", 2)
+		if len(size) != 2 {
+			return nil, fmt.Errorf("%s: %w", item, ErrInvalidVariant)
+		}
+
+		if v.Width, err = strconv.Atoi(size[0]); err != nil {
+			return nil, fmt.Errorf("%s: %w", item, ErrInvalidVariant)
+		}
+
+		if v.Height, err = strconv.Atoi(size[1]); err != nil {
+			return nil, fmt.Errorf("%s: %w", item, ErrInvalidVariant)
+		}
+
+		for _, p := range parts[2:] {
+			switch p {
+			case string(ModeFit), string(ModeFill):
+				v.Mode = Mode(p)
+			case FormatJPEG, FormatPNG:
+				v.Format = p
+			default:
+				return nil, fmt.Errorf("%s: %w", item, ErrInvalidVariant)
+			}
+		}
+
+		variants = append(variants, v)
+	}
+
+	return variants, nil
+}
+
+// Store stores the original and then its variants if the file is an image of ContentTypes.
+// Options of the original are applied to the variants except the content type and ImageInfo
+func (s *ImagingStorage) Store(filePath, p string, options ...interface{}) (cLink string, err error) {
+	filePath, report, err := s.sanitize(filePath)
+	if err != nil {
+		return "", err
+	}
+	if report != nil {
+		defer os.Remove(filePath)
+	}
+
+	variants, info, err := s.prepare(filePath, path.Ext(p))
+	if err != nil {
+		return "", err
+	}
+	defer removeFiles(variants)
+
+	cLink, err = s.cfg.StorageCtl.Store(filePath, p, withInfo(options, info)...)
+	if err != nil {
+		return "", err // nolint:wrapcheck
+	}
+
+	if err = s.storeVariants(cLink, variants, options); err != nil {
+		return "", err
+	}
+
+	s.reportSanitized(cLink, report)
+
+	return cLink, nil
+}
+
+func (s *ImagingStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
+	filePath, report, err := s.sanitize(filePath)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		defer os.Remove(filePath)
+	}
+
+	variants, info, err := s.prepare(filePath, path.Ext(common.CLinkToPath(s.cfg.StorageKey, cLink)))
+	if err != nil {
+		return err
+	}
+	defer removeFiles(variants)
+
+	if err = s.cfg.StorageCtl.StoreByCLink(filePath, cLink, withInfo(options, info)...); err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	if err = s.storeVariants(cLink, variants, options); err != nil {
+		return err
+	}
+
+	s.reportSanitized(cLink, report)
+
+	return nil
+}
+
+func (s *ImagingStorage) GetURL(cLink string, options ...interface{}) string {
+	return s.cfg.StorageCtl.GetURL(cLink, options...)
+}
+
+// Remove removes the object and its variants, missing variants are ignored
+func (s *ImagingStorage) Remove(cLink string) (err error) {
+	if err = s.cfg.StorageCtl.Remove(cLink); err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	for _, variant := range s.variantCLinks(cLink) {
+		s.cfg.StorageCtl.Remove(variant) // nolint:errcheck
+	}
+
+	return nil
+}
+
+// RemoveMany removes the objects and their variants,
+// variants listed after their originals are reported as removed
+func (s *ImagingStorage) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	removed := make(map[string]bool)
+
+	for _, cLink := range cLinks {
+		if removed[cLink] {
+			results = append(results, core.DeleteResult{CLink: cLink})
+			continue
+		}
+
+		err := s.Remove(cLink)
+		if err == nil {
+			for _, variant := range s.variantCLinks(cLink) {
+				removed[variant] = true
+			}
+		}
+
+		results = append(results, core.DeleteResult{CLink: cLink, Err: err})
+	}
+
+	return results
+}
+
+func (s *ImagingStorage) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	return core.RemovePrefix(s.cfg.StorageCtl, prefix) // nolint:wrapcheck
+}
+
+func (s *ImagingStorage) GetCLink(p string) (cLink string) {
+	return s.cfg.StorageCtl.GetCLink(p)
+}
+
+func (s *ImagingStorage) Unwrap() core.Storage {
+	return s.cfg.StorageCtl
+}
+
+// VariantCLink returns cLink of the variant of the image by cLink
+func (s *ImagingStorage) VariantCLink(cLink, name string) (string, error) {
+	p, err := s.VariantPath(common.CLinkToPath(s.cfg.StorageKey, cLink), name)
+	if err != nil {
+		return "", err
+	}
+
+	return s.GetCLink(p), nil
+}
+
+// VariantPath returns path of the variant: the name is added before the extension of the format,
+// e.g. "photos/cat.thumb.jpg" for "photos/cat.jpg"
+func (s *ImagingStorage) VariantPath(p, name string) (string, error) {
+	for _, v := range s.cfg.Variants {
+		if v.Name == name {
+			return s.variantPath(p, v), nil
+		}
+	}
+
+	return "", fmt.Errorf("%s: %w", name, ErrUnknownVariant)
+}
+
+func (s *ImagingStorage) variantPath(p string, v Variant) string {
+	ext := ".png"
+	if variantFormat(v, path.Ext(p)) == FormatJPEG {
+		ext = ".jpg"
+	}
+
+	return strings.TrimSuffix(p, path.Ext(p)) + "." + v.Name + ext
+}
+
+// variantCLinks returns cLinks of all variants of the image
+func (s *ImagingStorage) variantCLinks(cLink string) (cLinks []string) {
+	p := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	if !isImagePath(p) {
+		return nil
+	}
+
+	for _, v := range s.cfg.Variants {
+		cLinks = append(cLinks, s.GetCLink(s.variantPath(p, v)))
+	}
+
+	return cLinks
+}
+
+// DerivedFrom returns possible paths of the original of the variant at path, nil for other objects.
+// The extension of the original is not kept in the variant path, so all image extensions are returned.
+// Use it as gc.Config.DerivedFrom to keep the variants of the referenced images
+func (s *ImagingStorage) DerivedFrom(p string) (origins []string) {
+	ext := path.Ext(p)
+	if ext != ".jpg" && ext != ".png" {
+		return nil
+	}
+
+	base := strings.TrimSuffix(p, ext)
+
+	for _, v := range s.cfg.Variants {
+		if !strings.HasSuffix(base, "."+v.Name) {
+			continue
+		}
+
+		name := strings.TrimSuffix(base, "."+v.Name)
+
+		for _, originExt := range []string{".jpg", ".jpeg", ".png", ".gif", ".JPG", ".JPEG", ".PNG", ".GIF"} {
+			// the path is derived by variantPath with the format of this extension
+			if s.variantPath(name+originExt, v) == p {
+				origins = append(origins, name+originExt)
+			}
+		}
+	}
+
+	return origins
+}
+
+// variantFormat returns the format of the variant for the original with the extension
+func variantFormat(v Variant, ext string) string {
+	if v.Format != "" {
+		return v.Format
+	}
+
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return FormatJPEG
+	default:
+		return FormatPNG
+	}
+}
+
+func isImagePath(p string) bool {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// sanitize writes the sanitized copy of JPEG or PNG to the temp file, the caller removes it.
+// The file is returned as is with nil report if sanitizing is off or it is not JPEG or PNG
+func (s *ImagingStorage) sanitize(filePath string) (string, *SanitizeReport, error) {
+	if !s.cfg.Sanitize {
+		return filePath, nil, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if contentType := common.GetFileContentType(f); contentType != "image/jpeg" && contentType != "image/png" {
+		return filePath, nil, nil
+	}
+
+	out, err := os.CreateTemp(s.cfg.TempDir, "imaging-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer out.Close()
+
+	report, err := Sanitize(f, out, s.cfg.Quality, s.cfg.MaxPixels)
+	if err != nil {
+		os.Remove(out.Name())
+		return "", nil, err
+	}
+
+	return out.Name(), &report, nil
+}
+
+func (s *ImagingStorage) reportSanitized(cLink string, report *SanitizeReport) {
+	if report != nil && s.cfg.OnSanitize != nil {
+		s.cfg.OnSanitize(cLink, *report)
+	}
+}
+
+// variantFile - encoded variant waiting for upload
+type variantFile struct {
+	variant  Variant
+	filePath string
+}
+
+// prepare decodes the image, encodes its variants to temp files and extracts ImageInfo, nothing is done for other files.
+// ext is the extension of the original path, it selects the default format of the variants
+func (s *ImagingStorage) prepare(filePath, ext string) (files []variantFile, info *ImageInfo, err error) {
+	if len(s.cfg.Variants) == 0 && !s.cfg.ExtractMetadata {
+		return nil, nil, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if !s.match(common.GetFileContentType(f)) {
+		return nil, nil, nil
+	}
+
+	src, err := decode(f, s.cfg.MaxPixels)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.cfg.ExtractMetadata {
+		i := extract(src)
+		info = &i
+	}
+
+	for _, v := range s.cfg.Variants {
+		vf := variantFile{variant: v}
+
+		if vf.filePath, err = encode(resize(src, v), variantFormat(v, ext), s.cfg.Quality, s.cfg.TempDir); err != nil {
+			removeFiles(files)
+			return nil, nil, err
+		}
+
+		files = append(files, vf)
+	}
+
+	return files, info, nil
+}
+
+// storeVariants stores variants of the stored object by cLink. If one of them fails,
+// the object and the stored variants are removed, the upload is not left half done
+func (s *ImagingStorage) storeVariants(cLink string, files []variantFile, options []interface{}) error {
+	p := common.CLinkToPath(s.cfg.StorageKey, cLink)
+
+	stored := []string{cLink}
+
+	for _, vf := range files {
+		format := variantFormat(vf.variant, path.Ext(p))
+
+		variant, err := s.cfg.StorageCtl.Store(vf.filePath, s.variantPath(p, vf.variant),
+			append(options[:len(options):len(options)], core.ContentType("image/"+format))...)
+		if err != nil {
+			for _, c := range stored {
+				s.cfg.StorageCtl.Remove(c) // nolint:errcheck
+			}
+
+			return fmt.Errorf("failed to store variant %s: %w", vf.variant.Name, err)
+		}
+
+		stored = append(stored, variant)
+	}
+
+	return nil
+}
+
+func (s *ImagingStorage) match(contentType string) bool {
+	for _, t := range s.cfg.ContentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decode decodes the image, JPEG is rotated by its EXIF orientation.
+// The size is checked before decoding, a small file can declare huge dimensions
+func decode(r io.ReadSeeker, maxPixels int) (*image.RGBA, error) {
+	if err := checkPixels(r, maxPixels); err != nil {
+		return nil, err
+	}
+
+	img, format, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err) // nolint:errorlint
+	}
+
+	src := toRGBA(img)
+
+	if format == FormatJPEG {
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		src = orient(src, orientation(readExif(r)))
+	}
+
+	return src, nil
+}
+
+// checkPixels refuses images larger than maxPixels by their header and rewinds r
+func checkPixels(r io.ReadSeeker, maxPixels int) error {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImage, err) // nolint:errorlint
+	}
+
+	if maxPixels <= 0 {
+		maxPixels = DefaultMaxPixels
+	}
+
+	if int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return fmt.Errorf("%w: %dx%d", ErrTooManyPixels, cfg.Width, cfg.Height)
+	}
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return nil
+}
+
+// encode writes the image to the temp file, the caller removes it
+func encode(img image.Image, format string, quality int, tempDir string) (string, error) {
+	f, err := os.CreateTemp(tempDir, "imaging-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer f.Close()
+
+	if format == FormatJPEG {
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(f, img)
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+
+	return f.Name(), nil
+}
+
+// withInfo returns the options with ImageInfo as user metadata
+func withInfo(options []interface{}, info *ImageInfo) []interface{} {
+	if info == nil {
+		return options
+	}
+
+	return append(options[:len(options):len(options)], info.metadata())
+}
+
+func resize(img *image.RGBA, v Variant) *image.RGBA {
+	if v.Mode == ModeFill && v.Width > 0 && v.Height > 0 {
+		return fill(img, v.Width, v.Height)
+	}
+
+	return fit(img, v.Width, v.Height)
+}
+
+func removeFiles(files []variantFile) {
+	for _, vf := range files {
+		os.Remove(vf.filePath)
+	}
+}
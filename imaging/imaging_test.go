@@ -0,0 +1,563 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+var testStorageKey = "images"
+
+func newTestStorage(t *testing.T, variants ...Variant) (*ImagingStorage, string) {
+	root := t.TempDir()
+
+	return New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: local.New(&local.Config{
+			StorageKey: testStorageKey,
+			Root:       root,
+			BufferSize: 32 * 1024,
+		}),
+		Variants: variants,
+		TempDir:  t.TempDir(),
+	}), root
+}
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	return img
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	f := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(f, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func decodeFile(t *testing.T, name string) (image.Config, string) {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("variant is not stored: %v", err)
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("DecodeConfig err: %q", err)
+	}
+
+	return cfg, format
+}
+
+func TestStoreVariants(t *testing.T) {
+	s, root := newTestStorage(t,
+		Variant{Name: "thumb", Width: 100, Height: 100, Mode: ModeFill, Format: FormatJPEG},
+		Variant{Name: "medium", Width: 200},
+		Variant{Name: "big", Width: 1000, Height: 1000},
+	)
+
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(400, 200)) // nolint:errcheck
+
+	cLink, err := s.Store(writeFile(t, buf.Bytes()), "photos/cat.png")
+	if err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	flagtests := []struct {
+		name   string
+		path   string
+		format string
+		width  int
+		height int
+	}{
+		{"thumb", "photos/cat.thumb.jpg", "jpeg", 100, 100},
+		{"medium", "photos/cat.medium.png", "png", 200, 100},
+		{"big", "photos/cat.big.png", "png", 400, 200},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			vLink, err := s.VariantCLink(cLink, tt.name)
+			if err != nil || vLink != s.GetCLink(tt.path) {
+				t.Errorf("VariantCLink got %q, %v, want %q", vLink, err, s.GetCLink(tt.path))
+			}
+
+			cfg, format := decodeFile(t, filepath.Join(root, tt.path))
+			if format != tt.format || cfg.Width != tt.width || cfg.Height != tt.height {
+				t.Errorf("got %s %dx%d, want %s %dx%d", format, cfg.Width, cfg.Height, tt.format, tt.width, tt.height)
+			}
+		})
+	}
+
+	if _, err = s.VariantCLink(cLink, "huge"); !errors.Is(err, ErrUnknownVariant) {
+		t.Errorf("got %v, want %v", err, ErrUnknownVariant)
+	}
+
+	if err = s.Remove(cLink); err != nil {
+		t.Fatalf("Remove err: %q", err)
+	}
+
+	for _, tt := range flagtests {
+		if _, err = os.Stat(filepath.Join(root, tt.path)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("variant %s is not removed", tt.name)
+		}
+	}
+}
+
+// failingStorage - local which fails to store paths containing fail
+type failingStorage struct {
+	*local.Local
+	fail string
+}
+
+func (f failingStorage) Store(filePath, path string, options ...interface{}) (string, error) {
+	if strings.Contains(path, f.fail) {
+		return "", errors.New("store failed")
+	}
+
+	return f.Local.Store(filePath, path, options...)
+}
+
+func TestStoreVariantFailed(t *testing.T) {
+	root := t.TempDir()
+	s := New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: failingStorage{
+			Local: local.New(&local.Config{StorageKey: testStorageKey, Root: root, BufferSize: 32 * 1024}),
+			fail:  ".medium.",
+		},
+		Variants: []Variant{{Name: "thumb", Width: 100}, {Name: "medium", Width: 200}},
+		TempDir:  t.TempDir(),
+	})
+
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(400, 200)) // nolint:errcheck
+
+	if _, err := s.Store(writeFile(t, buf.Bytes()), "photos/cat.png"); err == nil {
+		t.Fatalf("Store succeeded without variant")
+	}
+
+	// the original and the stored variants are not left behind
+	for _, p := range []string{"photos/cat.png", "photos/cat.thumb.png"} {
+		if _, err := os.Stat(filepath.Join(root, p)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s is not removed", p)
+		}
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	s, root := newTestStorage(t, Variant{Name: "thumb", Width: 100, Height: 100})
+
+	data := exifJPEG(testImage(40, 20), 6, false)
+
+	if _, err := s.Store(writeFile(t, data), "rotated.jpg"); err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	cfg, _ := decodeFile(t, filepath.Join(root, "rotated.thumb.jpg"))
+	if cfg.Width != 20 || cfg.Height != 40 {
+		t.Errorf("got %dx%d, want 20x40", cfg.Width, cfg.Height)
+	}
+}
+
+// exifJPEG returns JPEG with APP1 Exif segment: orientation and optional GPS IFD pointer in IFD0
+func exifJPEG(img image.Image, orientation uint16, gps bool) []byte {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, nil) // nolint:errcheck
+
+	entries := [][]byte{{0x01, 0x12, 0x00, 0x03, 0, 0, 0, 1, byte(orientation >> 8), byte(orientation), 0, 0}}
+	if gps {
+		entries = append(entries, []byte{0x88, 0x25, 0x00, 0x04, 0, 0, 0, 1, 0, 0, 0, 0x1a})
+	}
+
+	tiff := []byte{'M', 'M', 0x00, 0x2a, 0, 0, 0, 8, 0, byte(len(entries))}
+	for _, e := range entries {
+		tiff = append(tiff, e...)
+	}
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	app1 := append([]byte("\xff\xe1\x00\x00Exif\x00\x00"), tiff...)
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(app1)-2))
+
+	return append(append([]byte("\xff\xd8"), app1...), buf.Bytes()[2:]...)
+}
+
+func encodePNG(img image.Image) *bytes.Reader {
+	var buf bytes.Buffer
+	png.Encode(&buf, img) // nolint:errcheck
+
+	return bytes.NewReader(buf.Bytes())
+}
+
+// hugePNG returns small PNG with the header declaring w x h pixels
+func hugePNG(w, h uint32) []byte {
+	data, _ := io.ReadAll(encodePNG(testImage(1, 1)))
+
+	binary.BigEndian.PutUint32(data[16:], w)
+	binary.BigEndian.PutUint32(data[20:], h)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	return data
+}
+
+func TestMaxPixels(t *testing.T) {
+	s, _ := newTestStorage(t, Variant{Name: "thumb", Width: 100, Height: 100})
+
+	if _, err := s.Store(writeFile(t, hugePNG(50000, 50000)), "huge.png"); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("got %v, want %v", err, ErrTooManyPixels)
+	}
+
+	if _, err := Extract(bytes.NewReader(hugePNG(50000, 50000)), 0); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("got %v, want %v", err, ErrTooManyPixels)
+	}
+
+	// rotated JPEG is decoded by Sanitize
+	rotated := exifJPEG(testImage(40, 20), 6, false)
+	if _, err := Sanitize(bytes.NewReader(rotated), io.Discard, 90, 100); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("got %v, want %v", err, ErrTooManyPixels)
+	}
+}
+
+func TestStoreNotImage(t *testing.T) {
+	s, root := newTestStorage(t, Variant{Name: "thumb", Width: 100, Height: 100})
+
+	if _, err := s.Store(writeFile(t, []byte("hello")), "a.txt"); err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "a.thumb.png")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("variant of not image is stored")
+	}
+
+	broken := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00")
+	if _, err := s.Store(writeFile(t, broken), "b.png"); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("got %v, want %v", err, ErrInvalidImage)
+	}
+}
+
+func TestParseVariants(t *testing.T) {
+	variants, err := ParseVariants("thumb:200x200:fill:jpeg, medium:800x0")
+	if err != nil {
+		t.Fatalf("ParseVariants err: %q", err)
+	}
+
+	want := []Variant{
+		{Name: "thumb", Width: 200, Height: 200, Mode: ModeFill, Format: FormatJPEG},
+		{Name: "medium", Width: 800},
+	}
+
+	if len(variants) != len(want) || variants[0] != want[0] || variants[1] != want[1] {
+		t.Errorf("got %v, want %v", variants, want)
+	}
+
+	for _, s := range []string{"thumb", "thumb:200", "thumb:axb", "thumb:1x1:crop"} {
+		if _, err = ParseVariants(s); !errors.Is(err, ErrInvalidVariant) {
+			t.Errorf("%s: got %v, want %v", s, err, ErrInvalidVariant)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	originals, _ := newTestStorage(t)
+	cacheRoot := t.TempDir()
+
+	aStorage := core.New()
+	aStorage.AddStorage(testStorageKey, originals)
+	aStorage.AddStorage("resized", local.New(&local.Config{
+		StorageKey: "resized",
+		Root:       cacheRoot,
+		BufferSize: 32 * 1024,
+	}))
+
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(400, 200)) // nolint:errcheck
+
+	cLink, _ := originals.Store(writeFile(t, buf.Bytes()), "photos/cat.png")
+	textLink, _ := originals.Store(writeFile(t, []byte("hello")), "a.txt")
+	spacedLink, _ := originals.Store(writeFile(t, buf.Bytes()), "photos/my cat #1?.png")
+
+	h := NewHandler(&HandlerConfig{
+		Storage:         aStorage,
+		CacheStorageKey: "resized",
+		SigningKey:      "secret",
+		MaxWidth:        1000,
+		TempDir:         t.TempDir(),
+	})
+
+	thumb := h.Path(cLink, Variant{Width: 100, Height: 100, Mode: ModeFill, Format: FormatJPEG})
+
+	flagtests := []struct {
+		name   string
+		target string
+		code   int
+		format string
+		width  int
+		height int
+	}{
+		{"fill", thumb, http.StatusOK, "jpeg", 100, 100},
+		{"cached", thumb, http.StatusOK, "jpeg", 100, 100},
+		{"fit", h.Path(cLink, Variant{Width: 100}), http.StatusOK, "png", 100, 50},
+		{"escaped", h.Path(spacedLink, Variant{Width: 100}), http.StatusOK, "png", 100, 50},
+		{"tampered", strings.Replace(thumb, "w=100", "w=200", 1), http.StatusForbidden, "", 0, 0},
+		{"unsigned", "/images/photos/cat.png?w=100", http.StatusForbidden, "", 0, 0},
+		{"too wide", h.Path(cLink, Variant{Width: 2000}), http.StatusBadRequest, "", 0, 0},
+		{"bad mode", h.Path(cLink, Variant{Width: 10, Mode: "crop"}), http.StatusBadRequest, "", 0, 0},
+		{"missing", h.Path(testStorageKey+":photos/dog.png", Variant{Width: 10}), http.StatusNotFound, "", 0, 0},
+		{"not image", h.Path(textLink, Variant{Width: 10}), http.StatusUnsupportedMediaType, "", 0, 0},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.code {
+				t.Fatalf("got %d, want %d", rec.Code, tt.code)
+			}
+
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			if ct := rec.Header().Get("Content-Type"); ct != "image/"+tt.format {
+				t.Errorf("got Content-Type %q, want image/%s", ct, tt.format)
+			}
+
+			cfg, format, err := image.DecodeConfig(rec.Body)
+			if err != nil || format != tt.format || cfg.Width != tt.width || cfg.Height != tt.height {
+				t.Errorf("got %s %dx%d, %v, want %s %dx%d", format, cfg.Width, cfg.Height, err, tt.format, tt.width, tt.height)
+			}
+		})
+	}
+
+	// requests waiting for the failed resize fail too
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, h.Path(textLink, Variant{Width: 20}), nil))
+
+			if rec.Code != http.StatusUnsupportedMediaType {
+				t.Errorf("concurrent: got %d, want %d", rec.Code, http.StatusUnsupportedMediaType)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := h.Purge(cLink); err != nil {
+		t.Fatalf("Purge err: %q", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(cacheRoot, "images/photos/cat.png")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("resized images are not purged")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	data := exifJPEG(testImage(40, 20), 1, true)
+
+	// XMP and comment segments after Exif
+	xmp := append([]byte("\xff\xe1\x00\x00"), xmpHeader...)
+	xmp = append(xmp, "<x:xmpmeta/>"...)
+	binary.BigEndian.PutUint16(xmp[2:], uint16(len(xmp)-2))
+
+	extra := append(xmp, "\xff\xfe\x00\x07hello"...)
+	data = append(append([]byte{}, data[:2]...), append(extra, data[2:]...)...)
+
+	var out bytes.Buffer
+
+	report, err := Sanitize(bytes.NewReader(data), &out, 90, 0)
+	if err != nil {
+		t.Fatalf("Sanitize err: %q", err)
+	}
+
+	if strings.Join(report.Removed, ",") != "xmp,comment,exif" || !report.GPS || report.Oriented {
+		t.Errorf("got %+v, want xmp, comment and exif with GPS", report)
+	}
+
+	if bytes.Contains(out.Bytes(), exifHeader) || bytes.Contains(out.Bytes(), []byte("hello")) {
+		t.Errorf("metadata is not removed")
+	}
+
+	if len(data)-out.Len() != report.Bytes {
+		t.Errorf("got %d removed bytes, want %d", report.Bytes, len(data)-out.Len())
+	}
+
+	if _, err = jpeg.Decode(&out); err != nil {
+		t.Errorf("sanitized JPEG is not decoded: %v", err)
+	}
+
+	// MPF segment and the secondary image with its own Exif after the end of the primary one
+	mpf := append([]byte("\xff\xe2\x00\x0a"), "MPF\x00\x00\x00\x00\x00"...)
+	primary := exifJPEG(testImage(40, 20), 1, false)
+	secondary := exifJPEG(testImage(8, 8), 1, true)
+	data = append(append(append(append([]byte{}, primary[:2]...), mpf...), primary[2:]...), secondary...)
+
+	out.Reset()
+
+	if report, err = Sanitize(bytes.NewReader(data), &out, 90, 0); err != nil {
+		t.Fatalf("Sanitize err: %q", err)
+	}
+
+	if strings.Join(report.Removed, ",") != "mpf,exif,trailer" || len(data)-out.Len() != report.Bytes {
+		t.Errorf("got %+v, want removed mpf, exif and trailer", report)
+	}
+
+	if bytes.Contains(out.Bytes(), exifHeader) || !bytes.HasSuffix(out.Bytes(), []byte{0xff, markerEOI}) {
+		t.Errorf("secondary image is not removed")
+	}
+
+	if _, err = jpeg.Decode(bytes.NewReader(out.Bytes())); err != nil {
+		t.Errorf("sanitized JPEG is not decoded: %v", err)
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(4, 4)) // nolint:errcheck
+
+	// tEXt chunk after IHDR, the checksum is not verified
+	text := []byte("\x00\x00\x00\x0btEXtAuthor\x00John\x00\x00\x00\x00")
+	pngData := append(append(append([]byte{}, buf.Bytes()[:33]...), text...), buf.Bytes()[33:]...)
+
+	out.Reset()
+
+	if report, err = Sanitize(bytes.NewReader(pngData), &out, 90, 0); err != nil {
+		t.Fatalf("Sanitize err: %q", err)
+	}
+
+	if strings.Join(report.Removed, ",") != "text" || !bytes.Equal(out.Bytes(), buf.Bytes()) {
+		t.Errorf("got %+v, want removed text chunk", report)
+	}
+
+	if _, err = Sanitize(strings.NewReader("hello"), &out, 90, 0); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("got %v, want %v", err, ErrInvalidImage)
+	}
+}
+
+func TestStoreSanitized(t *testing.T) {
+	root := t.TempDir()
+
+	var reports []SanitizeReport
+
+	s := New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: local.New(&local.Config{
+			StorageKey: testStorageKey,
+			Root:       root,
+			BufferSize: 32 * 1024,
+		}),
+		Sanitize:   true,
+		OnSanitize: func(cLink string, report SanitizeReport) { reports = append(reports, report) },
+	})
+
+	if _, err := s.Store(writeFile(t, exifJPEG(testImage(40, 20), 6, true)), "photo.jpg"); err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	stored, _ := os.ReadFile(filepath.Join(root, "photo.jpg"))
+	if bytes.Contains(stored, exifHeader) {
+		t.Errorf("Exif is stored")
+	}
+
+	cfg, _ := decodeFile(t, filepath.Join(root, "photo.jpg"))
+	if cfg.Width != 20 || cfg.Height != 40 {
+		t.Errorf("got %dx%d, want 20x40", cfg.Width, cfg.Height)
+	}
+
+	if len(reports) != 1 || !reports[0].GPS || !reports[0].Oriented {
+		t.Errorf("got %+v, want one report of oriented image with GPS", reports)
+	}
+
+	if _, err := s.Store(writeFile(t, []byte("hello")), "a.txt"); err != nil || len(reports) != 1 {
+		t.Errorf("not image is sanitized: %v", err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	red := image.NewUniform(color.RGBA{255, 0, 0, 255})
+
+	img := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	draw.Draw(img, img.Bounds(), red, image.Point{}, draw.Src)
+
+	info, err := Extract(encodePNG(img), 0)
+	if err != nil {
+		t.Fatalf("Extract err: %q", err)
+	}
+
+	// value of the reference algorithm
+	want := "LGTI:j;$fQ;$|co1fQo1fQfQfQfQ"
+
+	if info.Width != 30 || info.Height != 20 || info.BlurHash != want || info.DominantColor != "#ff0000" {
+		t.Errorf("got %+v, want 30x20 %s #ff0000", info, want)
+	}
+
+	// the larger part wins
+	draw.Draw(img, image.Rect(0, 0, 10, 20), image.NewUniform(color.RGBA{0, 0, 255, 255}), image.Point{}, draw.Src)
+
+	if info, _ = Extract(encodePNG(img), 0); info.DominantColor != "#ff0000" {
+		t.Errorf("got %s, want #ff0000", info.DominantColor)
+	}
+}
+
+func TestStoreMetadata(t *testing.T) {
+	s := New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: local.New(&local.Config{
+			StorageKey: testStorageKey,
+			Root:       t.TempDir(),
+			BufferSize: 32 * 1024,
+		}),
+		ExtractMetadata: true,
+	})
+
+	cLink, err := s.Store(writeFile(t, exifJPEG(testImage(40, 20), 6, false)), "photo.jpg")
+	if err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	info, err := s.ImageInfo(cLink)
+	if err != nil {
+		t.Fatalf("ImageInfo err: %q", err)
+	}
+
+	if info.Width != 20 || info.Height != 40 || len(info.BlurHash) != 28 || len(info.DominantColor) != 7 {
+		t.Errorf("got %+v, want 20x40 with placeholder", info)
+	}
+
+	textLink, _ := s.Store(writeFile(t, []byte("hello")), "a.txt")
+	if _, err = s.ImageInfo(textLink); !errors.Is(err, ErrNoImageInfo) {
+		t.Errorf("got %v, want %v", err, ErrNoImageInfo)
+	}
+}
@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+
+	"github.com/rosberry/storage/core"
+)
+
+// ImageInfo - dimensions and placeholder of the image, saved as user metadata of the object
+type ImageInfo struct {
+	Width         int    // as displayed, after Exif orientation
+	Height        int    // as displayed, after Exif orientation
+	BlurHash      string // 4x3 components
+	DominantColor string // "#rrggbb"
+}
+
+// user metadata keys of ImageInfo
+const (
+	metaWidth         = "image-width"
+	metaHeight        = "image-height"
+	metaBlurHash      = "image-blurhash"
+	metaDominantColor = "image-dominant-color"
+
+	// the placeholder is computed from the small copy of the image
+	placeholderSize = 64
+)
+
+var ErrNoImageInfo = errors.New("no image info")
+
+// Extract returns ImageInfo of JPEG, PNG or GIF image.
+// Images larger than maxPixels are not decoded, 0 - DefaultMaxPixels
+func Extract(r io.ReadSeeker, maxPixels int) (ImageInfo, error) {
+	src, err := decode(r, maxPixels)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+
+	return extract(src), nil
+}
+
+func extract(src *image.RGBA) ImageInfo {
+	small := fit(src, placeholderSize, placeholderSize)
+
+	return ImageInfo{
+		Width:         src.Bounds().Dx(),
+		Height:        src.Bounds().Dy(),
+		BlurHash:      blurHash(small, 4, 3),
+		DominantColor: dominantColor(small),
+	}
+}
+
+// ParseImageInfo returns ImageInfo from the metadata of the object, e.g. returned by Stat
+func ParseImageInfo(info core.ObjectInfo) (i ImageInfo, err error) {
+	if info.Metadata[metaWidth] == "" {
+		return i, fmt.Errorf("%s: %w", info.Path, ErrNoImageInfo)
+	}
+
+	i.Width, _ = strconv.Atoi(info.Metadata[metaWidth])
+	i.Height, _ = strconv.Atoi(info.Metadata[metaHeight])
+	i.BlurHash = info.Metadata[metaBlurHash]
+	i.DominantColor = info.Metadata[metaDominantColor]
+
+	return i, nil
+}
+
+// ImageInfo returns ImageInfo of the object stored with ExtractMetadata
+func (s *ImagingStorage) ImageInfo(cLink string) (ImageInfo, error) {
+	info, err := core.Stat(s.cfg.StorageCtl, cLink)
+	if err != nil {
+		return ImageInfo{}, err // nolint:wrapcheck
+	}
+
+	return ParseImageInfo(info)
+}
+
+// metadata - Store option with ImageInfo
+func (i ImageInfo) metadata() core.UserMetadata {
+	return core.UserMetadata{
+		metaWidth:         strconv.Itoa(i.Width),
+		metaHeight:        strconv.Itoa(i.Height),
+		metaBlurHash:      i.BlurHash,
+		metaDominantColor: i.DominantColor,
+	}
+}
+
+// dominantColor returns the average color of the most populated cell of 16x16x16 color cube,
+// transparent pixels are skipped
+func dominantColor(img *image.RGBA) string {
+	type cell struct {
+		count   int
+		r, g, b int
+	}
+
+	cells := make(map[int]*cell)
+
+	var top *cell
+
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		r, g, b, a := int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2]), int(img.Pix[i+3])
+		if a < 128 {
+			continue
+		}
+
+		// the pixels are premultiplied
+		r, g, b = r*255/a, g*255/a, b*255/a
+
+		key := r>>4<<8 | g>>4<<4 | b>>4
+
+		c, ok := cells[key]
+		if !ok {
+			c = &cell{}
+			cells[key] = c
+		}
+
+		c.count++
+		c.r += r
+		c.g += g
+		c.b += b
+
+		if top == nil || c.count > top.count {
+			top = c
+		}
+	}
+
+	if top == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", top.r/top.count, top.g/top.count, top.b/top.count)
+}
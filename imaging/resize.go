@@ -0,0 +1,179 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// contrib - weight of the source pixel i for one destination pixel
+type contrib struct {
+	i int
+	w float32
+}
+
+// toRGBA converts img to RGBA with the origin at (0, 0)
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+
+	return dst
+}
+
+// fit scales img down to fit into width x height keeping the aspect ratio,
+// 0 - the side is not limited. Smaller images are not enlarged
+func fit(img *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+
+	scale := 1.0
+	if width > 0 {
+		scale = math.Min(scale, float64(width)/float64(sw))
+	}
+	if height > 0 {
+		scale = math.Min(scale, float64(height)/float64(sh))
+	}
+
+	if scale >= 1 {
+		return img
+	}
+
+	return resample(img, scaled(sw, scale), scaled(sh, scale))
+}
+
+// fill scales and crops img to exactly width x height from the center
+func fill(img *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// crop to the aspect ratio of the result first, then only the needed pixels are resampled
+	cw, ch := sw, sh
+	if sw*height > sh*width {
+		cw = scaled(sh, float64(width)/float64(height))
+	} else {
+		ch = scaled(sw, float64(height)/float64(width))
+	}
+
+	x, y := (sw-cw)/2, (sh-ch)/2
+	cropped := img.SubImage(image.Rect(x, y, x+cw, y+ch)).(*image.RGBA)
+
+	return resample(toRGBA(cropped), width, height)
+}
+
+func scaled(n int, scale float64) int {
+	v := int(math.Round(float64(n) * scale))
+	if v < 1 {
+		return 1
+	}
+
+	return v
+}
+
+// resample resizes img by separable triangle filter, its support grows with the downscale ratio,
+// so every source pixel contributes to the result
+func resample(img *image.RGBA, dw, dh int) *image.RGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+	if sw == dw && sh == dh {
+		return img
+	}
+
+	xw, yw := weights(sw, dw), weights(sh, dh)
+
+	// horizontal pass: dw x sh
+	tmp := make([]float32, dw*sh*4)
+
+	for y := 0; y < sh; y++ {
+		row := img.Pix[y*img.Stride:]
+
+		for x, cs := range xw {
+			var v [4]float32
+
+			for _, c := range cs {
+				p := row[c.i*4 : c.i*4+4]
+				for k := range v {
+					v[k] += float32(p[k]) * c.w
+				}
+			}
+
+			copy(tmp[(y*dw+x)*4:], v[:])
+		}
+	}
+
+	// vertical pass: dw x dh
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y, cs := range yw {
+		for x := 0; x < dw; x++ {
+			var v [4]float32
+
+			for _, c := range cs {
+				p := tmp[(c.i*dw+x)*4:]
+				for k := range v {
+					v[k] += p[k] * c.w
+				}
+			}
+
+			out := dst.Pix[y*dst.Stride+x*4:]
+			for k := range v {
+				out[k] = clamp(v[k])
+			}
+		}
+	}
+
+	return dst
+}
+
+func weights(srcLen, dstLen int) [][]contrib {
+	scale := float64(srcLen) / float64(dstLen)
+	support := math.Max(scale, 1)
+
+	out := make([][]contrib, dstLen)
+
+	for i := range out {
+		center := (float64(i)+0.5)*scale - 0.5
+		left := int(math.Ceil(center - support))
+		right := int(math.Floor(center + support))
+
+		var (
+			cs  []contrib
+			sum float32
+		)
+
+		for j := left; j <= right; j++ {
+			w := float32(1 - math.Abs(float64(j)-center)/support)
+			if w <= 0 {
+				continue
+			}
+
+			// edge pixels are repeated
+			k := j
+			if k < 0 {
+				k = 0
+			}
+			if k >= srcLen {
+				k = srcLen - 1
+			}
+
+			cs = append(cs, contrib{i: k, w: w})
+			sum += w
+		}
+
+		for k := range cs {
+			cs[k].w /= sum
+		}
+
+		out[i] = cs
+	}
+
+	return out
+}
+
+func clamp(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return uint8(v + 0.5)
+	}
+}
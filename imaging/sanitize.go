@@ -0,0 +1,263 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// SanitizeReport - metadata removed by Sanitize
+type SanitizeReport struct {
+	Format   string   // FormatJPEG or FormatPNG
+	Removed  []string // kinds of the removed metadata: "exif", "xmp", "iptc", "mpf", "comment", "text", "time", "trailer"
+	Bytes    int      // size of the removed metadata
+	GPS      bool     // the removed Exif contained GPS coordinates
+	Oriented bool     // the pixels are transformed by the Exif orientation, the image is encoded again
+}
+
+const (
+	markerEOI   = 0xd9
+	markerAPP2  = 0xe2
+	markerCOM   = 0xfe
+	markerAPP13 = 0xed
+
+	pngSignature = "\x89PNG\r\n\x1a\n"
+)
+
+var (
+	xmpHeader         = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	xmpExtendedHeader = []byte("http://ns.adobe.com/xmp/extension/\x00")
+	xmpKeyword        = []byte("XML:com.adobe.xmp\x00")
+	mpfHeader         = []byte("MPF\x00")
+)
+
+// Sanitize copies JPEG or PNG image from r to w without Exif, XMP, IPTC, comments and text chunks.
+// Data after the end of JPEG, e.g. secondary images of MPF with their own Exif, is removed too.
+// ICC profiles and other color data are kept. Not normal Exif orientation is applied to the pixels,
+// JPEG is encoded again with the quality in this case. Images larger than maxPixels are not decoded,
+// 0 - DefaultMaxPixels
+func Sanitize(r io.Reader, w io.Writer, quality, maxPixels int) (report SanitizeReport, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return report, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	var (
+		out  []byte
+		tiff []byte
+	)
+
+	switch {
+	case len(data) > 2 && data[0] == 0xff && data[1] == markerSOI:
+		report.Format = FormatJPEG
+		out, tiff, err = stripJPEG(data, &report)
+	case bytes.HasPrefix(data, []byte(pngSignature)):
+		report.Format = FormatPNG
+		out, tiff, err = stripPNG(data, &report)
+	default:
+		return report, fmt.Errorf("%w: not JPEG or PNG", ErrInvalidImage)
+	}
+
+	if err != nil {
+		return report, err
+	}
+
+	if _, ok := ifd0Tag(tiff, tagGPSInfo); ok {
+		report.GPS = true
+	}
+
+	if o := orientation(tiff); o != 1 {
+		report.Oriented = true
+		return report, reencode(out, w, report.Format, o, quality, maxPixels)
+	}
+
+	if _, err = w.Write(out); err != nil {
+		return report, fmt.Errorf("failed to write image: %w", err)
+	}
+
+	return report, nil
+}
+
+// stripJPEG removes metadata segments and data after the end of the image,
+// returns TIFF part of the removed Exif
+func stripJPEG(data []byte, report *SanitizeReport) (out, tiff []byte, err error) {
+	out = append(out, data[:2]...)
+	pos := 2
+
+	for {
+		if pos+2 > len(data) || data[pos] != 0xff {
+			return nil, nil, fmt.Errorf("%w: truncated JPEG", ErrInvalidImage)
+		}
+
+		// markers may be preceded by fill bytes
+		for pos+2 < len(data) && data[pos+1] == 0xff {
+			pos++
+		}
+
+		marker := data[pos+1]
+
+		// appended images (MPF) and other trailing data follow the end of the image
+		if marker == markerEOI {
+			out = append(out, data[pos:pos+2]...)
+
+			if trailer := len(data) - pos - 2; trailer > 0 {
+				report.remove("trailer", trailer)
+			}
+
+			return out, tiff, nil
+		}
+
+		if pos+4 > len(data) {
+			return nil, nil, fmt.Errorf("%w: truncated JPEG", ErrInvalidImage)
+		}
+
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) || end < pos+4 {
+			return nil, nil, fmt.Errorf("%w: truncated JPEG", ErrInvalidImage)
+		}
+
+		segment := data[pos+4 : end]
+		kind := ""
+
+		switch {
+		case marker == markerAPP1 && bytes.HasPrefix(segment, exifHeader):
+			kind = "exif"
+			tiff = segment[len(exifHeader):]
+		case marker == markerAPP1 && (bytes.HasPrefix(segment, xmpHeader) || bytes.HasPrefix(segment, xmpExtendedHeader)):
+			kind = "xmp"
+		case marker == markerAPP2 && bytes.HasPrefix(segment, mpfHeader):
+			kind = "mpf"
+		case marker == markerAPP13:
+			kind = "iptc"
+		case marker == markerCOM:
+			kind = "comment"
+		}
+
+		if kind == "" {
+			out = append(out, data[pos:end]...)
+		} else {
+			report.remove(kind, end-pos)
+		}
+
+		pos = end
+
+		// the image data is copied up to the next marker, a missing end of the image is tolerated
+		if marker == markerSOS {
+			scanEnd := scanDataEnd(data, pos)
+			out = append(out, data[pos:scanEnd]...)
+
+			if scanEnd == len(data) {
+				return out, tiff, nil
+			}
+
+			pos = scanEnd
+		}
+	}
+}
+
+// scanDataEnd returns the position of the marker after the entropy-coded data starting at pos.
+// Stuffed 0xff00 bytes and restart markers are the part of the data
+func scanDataEnd(data []byte, pos int) int {
+	for ; pos+1 < len(data); pos++ {
+		if data[pos] != 0xff {
+			continue
+		}
+
+		if next := data[pos+1]; next != 0x00 && next != 0xff && (next < 0xd0 || next > 0xd7) {
+			return pos
+		}
+	}
+
+	return len(data)
+}
+
+// stripPNG removes text, time and Exif chunks, returns the removed Exif
+func stripPNG(data []byte, report *SanitizeReport) (out, tiff []byte, err error) {
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+
+	for pos < len(data) {
+		if pos+12 > len(data) {
+			return nil, nil, fmt.Errorf("%w: truncated PNG", ErrInvalidImage)
+		}
+
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + size
+
+		if size < 0 || end > len(data) || end < pos {
+			return nil, nil, fmt.Errorf("%w: truncated PNG", ErrInvalidImage)
+		}
+
+		chunk := data[pos+8 : pos+8+size]
+		kind := ""
+
+		switch string(data[pos+4 : pos+8]) {
+		case "eXIf":
+			kind = "exif"
+			tiff = chunk
+		case "iTXt":
+			kind = "text"
+			if bytes.HasPrefix(chunk, xmpKeyword) {
+				kind = "xmp"
+			}
+		case "tEXt", "zTXt":
+			kind = "text"
+		case "tIME":
+			kind = "time"
+		}
+
+		if kind == "" {
+			out = append(out, data[pos:end]...)
+		} else {
+			report.remove(kind, end-pos)
+		}
+
+		pos = end
+	}
+
+	return out, tiff, nil
+}
+
+// reencode writes the image transformed by the orientation
+func reencode(data []byte, w io.Writer, format string, o, quality, maxPixels int) error {
+	r := bytes.NewReader(data)
+
+	if err := checkPixels(r, maxPixels); err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImage, err) // nolint:errorlint
+	}
+
+	dst := orient(toRGBA(img), o)
+
+	if format == FormatJPEG {
+		err = jpeg.Encode(w, dst, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(w, dst)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+
+	return nil
+}
+
+func (r *SanitizeReport) remove(kind string, size int) {
+	r.Bytes += size
+
+	for _, k := range r.Removed {
+		if k == kind {
+			return
+		}
+	}
+
+	r.Removed = append(r.Removed, kind)
+}
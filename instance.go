@@ -2,22 +2,35 @@ package storage
 
 import (
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rosberry/storage/bypass"
+	"github.com/rosberry/storage/cache"
 	"github.com/rosberry/storage/cloudfront"
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/compress"
 	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/imaging"
 	"github.com/rosberry/storage/local"
+	"github.com/rosberry/storage/mirror"
+	"github.com/rosberry/storage/pathgen"
+	"github.com/rosberry/storage/quota"
 	"github.com/rosberry/storage/s3"
+	"github.com/rosberry/storage/urlcache"
+	"github.com/rosberry/storage/validation"
 	"github.com/rosberry/storage/yos/v2"
 )
 
 const (
-	TypeBypass = "bypass"
-	TypeLocal = "local"
-	TypeS3 = "s3"
-	TypeCloudFront = "cf"
+	TypeBypass           = "bypass"
+	TypeLocal            = "local"
+	TypeS3               = "s3"
+	TypeCloudFront       = "cf"
 	TypeCloudFrontSigned = "cfs"
-	TypeYOS = "yos"
+	TypeYOS              = "yos"
+	TypeMirror           = "mirror"
 )
 
 func NewWithConfig(config *core.StoragesConfig) *core.AbstractStorage {
@@ -33,19 +46,32 @@ func NewWithConfig(config *core.StoragesConfig) *core.AbstractStorage {
 	for _, instance := range config.Instances {
 		//key := strings.ToLower(instance.Key)
 		key := instance.Key
-		
+
+		var s core.Storage
+
+		trash, _ := strconv.ParseBool(instance.Cfg["trash"])
+		trashRetention, _ := time.ParseDuration(instance.Cfg["trash_retention"])
+
 		switch instance.Type {
 		case TypeS3:
-			aStorage.AddStorage(key, s3.New(&s3.Config{
-				StorageKey:      key,
-				Region:          instance.Cfg["region"],
-				AccessKeyID:     instance.Cfg["access_key_id"],
-				SecretAccessKey: instance.Cfg["secret_access_key"],
-				BucketName:      instance.Cfg["bucket_name"],
-				Prefix:          instance.Cfg["prefix"],
-			}))
+			maxExpirationDays, _ := strconv.Atoi(instance.Cfg["max_expiration_days"])
+			checkVisibility, _ := strconv.ParseBool(instance.Cfg["check_visibility"])
+
+			s = s3.New(&s3.Config{
+				StorageKey:        key,
+				Region:            instance.Cfg["region"],
+				AccessKeyID:       instance.Cfg["access_key_id"],
+				SecretAccessKey:   instance.Cfg["secret_access_key"],
+				BucketName:        instance.Cfg["bucket_name"],
+				Prefix:            instance.Cfg["prefix"],
+				PathPolicy:        pathPolicy(instance.Cfg),
+				MaxExpirationDays: maxExpirationDays,
+				Trash:             trash,
+				TrashRetention:    trashRetention,
+				CheckVisibility:   checkVisibility,
+			})
 		case TypeCloudFront:
-			aStorage.AddStorage(key, cloudfront.New(&cloudfront.Config{
+			s = cloudfront.New(&cloudfront.Config{
 				StorageKey: key,
 				DomainName: instance.Cfg["domain_name"],
 				CFPrefix:   instance.Cfg["cf_prefix"],
@@ -56,10 +82,11 @@ func NewWithConfig(config *core.StoragesConfig) *core.AbstractStorage {
 					SecretAccessKey: instance.Cfg["secret_access_key"],
 					BucketName:      instance.Cfg["bucket_name"],
 					Prefix:          instance.Cfg["prefix"],
+					PathPolicy:      pathPolicy(instance.Cfg),
 				}),
-			}))
+			})
 		case TypeCloudFrontSigned:
-			aStorage.AddStorage(key, cloudfront.New(&cloudfront.Config{
+			s = cloudfront.New(&cloudfront.Config{
 				StorageKey:   key,
 				DomainName:   instance.Cfg["domain_name"],
 				CFPrefix:     instance.Cfg["cf_prefix"],
@@ -73,26 +100,71 @@ func NewWithConfig(config *core.StoragesConfig) *core.AbstractStorage {
 					SecretAccessKey: instance.Cfg["secret_access_key"],
 					BucketName:      instance.Cfg["bucket_name"],
 					Prefix:          instance.Cfg["prefix"],
+					PathPolicy:      pathPolicy(instance.Cfg),
 				}),
-			}))
+			})
 		case TypeYOS:
-			aStorage.AddStorage(key, yos.New(&yos.Config{
-				StorageKey:      key,
-				Region:          instance.Cfg["region"],
-				AccessKeyID:     instance.Cfg["access_key_id"],
-				SecretAccessKey: instance.Cfg["secret_access_key"],
-				BucketName:      instance.Cfg["bucket_name"],
-				Prefix:          instance.Cfg["prefix"],
-			}))
+			maxExpirationDays, _ := strconv.Atoi(instance.Cfg["max_expiration_days"])
+
+			s = yos.New(&yos.Config{
+				StorageKey:        key,
+				Region:            instance.Cfg["region"],
+				AccessKeyID:       instance.Cfg["access_key_id"],
+				SecretAccessKey:   instance.Cfg["secret_access_key"],
+				BucketName:        instance.Cfg["bucket_name"],
+				Prefix:            instance.Cfg["prefix"],
+				PathPolicy:        pathPolicy(instance.Cfg),
+				MaxExpirationDays: maxExpirationDays,
+				Trash:             trash,
+				TrashRetention:    trashRetention,
+			})
 		case TypeLocal:
-			aStorage.AddStorage(key, local.New(&local.Config{
+			janitorInterval, _ := time.ParseDuration(instance.Cfg["janitor_interval"])
+			versioning, _ := strconv.ParseBool(instance.Cfg["versioning"])
+			signedOnly, _ := strconv.ParseBool(instance.Cfg["signed_only"])
+			maxUploadSize, _ := strconv.ParseInt(instance.Cfg["max_upload_size"], 10, 64)
+
+			s = local.New(&local.Config{
 				StorageKey: key,
 				Endpoint:   instance.Cfg["endpoint"],
 				Root:       instance.Cfg["root"],
 				BufferSize: 32 * 1024, // TODO: Config?
-			}))
+				PathPolicy: pathPolicy(instance.Cfg),
+
+				JanitorInterval: janitorInterval,
+				Trash:           trash,
+				TrashRetention:  trashRetention,
+				Versioning:      versioning,
+				SigningKey:      instance.Cfg["signing_key"],
+				SignedOnly:      signedOnly,
+				MaxUploadSize:   maxUploadSize,
+			})
+		case TypeMirror:
+			s = newMirror(aStorage, key, instance.Cfg)
+			if s == nil {
+				continue
+			}
 		default:
 			log.Printf("Storage type '%s' not supported!", instance.Type)
+			continue
+		}
+
+		wrapped := wrap(key, s, instance.Cfg)
+		aStorage.AddStorage(key, wrapped)
+
+		// uploads by signed PUT URLs pass the wrappers as Store does
+		if l, ok := s.(*local.Local); ok {
+			l.SetUploadStorage(wrapped)
+		}
+
+		if name := instance.Cfg["path_generator"]; name != "" {
+			generator, err := pathgen.ByName(name, instance.Cfg["path_template"], instance.Cfg["path_generator_prefix"])
+			if err != nil {
+				log.Printf("Storage '%s': %v", key, err)
+				continue
+			}
+
+			aStorage.SetPathGenerator(key, generator)
 		}
 	}
 
@@ -101,4 +173,160 @@ func NewWithConfig(config *core.StoragesConfig) *core.AbstractStorage {
 	}
 
 	return aStorage
-}
\ No newline at end of file
+}
+
+// wrap applies optional wrappers configured in the instance config
+func wrap(key string, s core.Storage, cfg map[string]string) core.Storage {
+	if algorithm := cfg["compress"]; algorithm != "" {
+		level, _ := strconv.Atoi(cfg["compress_level"])
+
+		s = compress.New(&compress.Config{
+			StorageKey:   key,
+			StorageCtl:   s,
+			Algorithm:    algorithm,
+			Level:        level,
+			ContentTypes: splitList(cfg["compress_types"]),
+			PathPatterns: splitList(cfg["compress_paths"]),
+		})
+	}
+
+	if cfg["quota_max_object_size"] != "" || cfg["quota_max_bytes"] != "" || cfg["quota_max_objects"] != "" {
+		maxObjectSize, _ := strconv.ParseInt(cfg["quota_max_object_size"], 10, 64)
+		maxBytes, _ := strconv.ParseInt(cfg["quota_max_bytes"], 10, 64)
+		maxObjects, _ := strconv.ParseInt(cfg["quota_max_objects"], 10, 64)
+		counterTTL, _ := time.ParseDuration(cfg["quota_counter_ttl"])
+
+		s = quota.New(&quota.Config{
+			StorageKey:    key,
+			StorageCtl:    s,
+			MaxObjectSize: maxObjectSize,
+			Rules: []quota.Rule{{
+				Prefix:     cfg["quota_prefix"],
+				MaxBytes:   maxBytes,
+				MaxObjects: maxObjects,
+			}},
+			CounterTTL: counterTTL,
+		})
+	}
+
+	if cfg["image_variants"] != "" || cfg["image_sanitize"] != "" || cfg["image_metadata"] != "" {
+		list, err := imaging.ParseVariants(cfg["image_variants"])
+		if err != nil {
+			log.Printf("Storage '%s': invalid image_variants: %v", key, err)
+		}
+
+		quality, _ := strconv.Atoi(cfg["image_quality"])
+		maxPixels, _ := strconv.Atoi(cfg["image_max_pixels"])
+		sanitize, _ := strconv.ParseBool(cfg["image_sanitize"])
+		extractMetadata, _ := strconv.ParseBool(cfg["image_metadata"])
+
+		s = imaging.New(&imaging.Config{
+			StorageKey:      key,
+			StorageCtl:      s,
+			Variants:        list,
+			ContentTypes:    splitList(cfg["image_types"]),
+			Quality:         quality,
+			MaxPixels:       maxPixels,
+			Sanitize:        sanitize,
+			ExtractMetadata: extractMetadata,
+		})
+	}
+
+	// untrusted uploads are rejected before imaging decodes them
+	if cfg["allowed_types"] != "" || cfg["check_extension"] != "" || cfg["block_active_content"] != "" {
+		checkExtension, _ := strconv.ParseBool(cfg["check_extension"])
+		blockActiveContent, _ := strconv.ParseBool(cfg["block_active_content"])
+
+		s = validation.New(&validation.Config{
+			StorageKey:         key,
+			StorageCtl:         s,
+			AllowedTypes:       splitList(cfg["allowed_types"]),
+			CheckExtension:     checkExtension,
+			BlockActiveContent: blockActiveContent,
+		})
+	}
+
+	if dir := cfg["cache_dir"]; dir != "" {
+		maxSize, _ := strconv.ParseInt(cfg["cache_max_size"], 10, 64)
+
+		s = cache.New(&cache.Config{
+			StorageKey: key,
+			StorageCtl: s,
+			Dir:        dir,
+			MaxSize:    maxSize,
+		})
+	}
+
+	if ttl := cfg["url_cache_ttl"]; ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.Printf("Storage '%s': invalid url_cache_ttl: %v", key, err)
+		}
+
+		margin, _ := time.ParseDuration(cfg["url_cache_margin"])
+
+		s = urlcache.New(&urlcache.Config{
+			StorageCtl: s,
+			TTL:        d,
+			Margin:     margin,
+		})
+	}
+
+	return s
+}
+
+// newMirror builds mirror from the instances declared before it in the config
+func newMirror(aStorage *core.AbstractStorage, key string, cfg map[string]string) core.Storage {
+	primary, err := aStorage.GetStorage(strings.ToLower(cfg["primary"]))
+	if err != nil {
+		log.Printf("Mirror '%s': primary storage '%s': %v", key, cfg["primary"], err)
+		return nil
+	}
+
+	var replicas []core.Storage
+
+	for _, replicaKey := range splitList(cfg["replicas"]) {
+		replica, err := aStorage.GetStorage(strings.ToLower(replicaKey))
+		if err != nil {
+			log.Printf("Mirror '%s': replica storage '%s': %v", key, replicaKey, err)
+			return nil
+		}
+
+		replicas = append(replicas, replica)
+	}
+
+	return mirror.New(&mirror.Config{
+		StorageKey: key,
+		Primary:    primary,
+		Replicas:   replicas,
+		WriteMode:  mirror.WriteMode(cfg["write_mode"]),
+	})
+}
+
+// pathPolicy returns nil (default policy) if the policy is not configured
+func pathPolicy(cfg map[string]string) *common.PathPolicy {
+	if cfg["path_max_length"] == "" && cfg["path_forbidden_chars"] == "" && cfg["path_lowercase"] == "" {
+		return nil
+	}
+
+	policy := common.DefaultPathPolicy
+
+	if cfg["path_max_length"] != "" {
+		policy.MaxKeyLength, _ = strconv.Atoi(cfg["path_max_length"])
+	}
+
+	policy.ForbiddenChars = cfg["path_forbidden_chars"]
+	policy.Lowercase, _ = strconv.ParseBool(cfg["path_lowercase"])
+
+	return &policy
+}
+
+func splitList(s string) (list []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,110 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rosberry/storage/core"
+)
+
+// RemoveMany removes objects one by one, trash and versioning are respected
+func (b *Local) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	results = make([]core.DeleteResult, len(cLinks))
+
+	for i, cLink := range cLinks {
+		results[i] = core.DeleteResult{CLink: cLink, Err: b.Remove(cLink)}
+	}
+
+	return results
+}
+
+// RemovePrefix removes all objects which path starts with prefix
+// and then the directories left empty. Nothing outside Root is touched
+func (b *Local) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	if strings.Trim(prefix, "/") == "" {
+		return nil, core.ErrEmptyPrefix
+	}
+
+	normalized, internalPath, err := b.safeInternalPath(strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cLinks []string
+
+	err = b.List(prefix, func(info core.ObjectInfo) error {
+		cLinks = append(cLinks, info.CLink)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results = b.RemoveMany(cLinks)
+
+	dir := internalPath
+	if !strings.HasSuffix(prefix, "/") {
+		dir = filepath.Dir(internalPath)
+	}
+
+	if err = b.removeEmptyDirs(dir, normalized); err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// removeEmptyDirs removes empty directories inside dir which path starts with prefix.
+// Root and reserved directories are kept
+func (b *Local) removeEmptyDirs(dir, prefix string) error {
+	var dirs []string
+
+	err := filepath.Walk(dir, func(internalPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !fi.IsDir() {
+			return nil
+		}
+
+		path, err := b.relativePath(internalPath)
+		if err != nil || path == "." {
+			return nil // nolint:nilerr
+		}
+
+		if isReserved(path) {
+			return filepath.SkipDir
+		}
+
+		if strings.HasPrefix(path, prefix) {
+			dirs = append(dirs, internalPath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list directories: %w", err)
+	}
+
+	// children go after their parents in walk order
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err = os.Remove(dirs[i])
+		if err != nil && !errors.Is(err, os.ErrNotExist) && !isNotEmpty(dirs[i]) {
+			return fmt.Errorf("failed to remove directory: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func isNotEmpty(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	return err == nil && len(entries) > 0
+}
@@ -0,0 +1,159 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rosberry/storage/common"
+)
+
+// ttlDir is the TTL index: Root/.ttl/<path> keeps expiration time of the temporary object
+const ttlDir = ".ttl"
+
+// Expire removes objects stored with core.ExpiresAt which are expired (moves to trash in trash mode).
+// It is called by the janitor every JanitorInterval
+func (b *Local) Expire() (removed int, err error) {
+	root := b.pathToInternalPath(ttlDir)
+	now := time.Now()
+
+	err = filepath.Walk(root, func(indexPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, indexPath)
+		if err != nil {
+			return nil // nolint:nilerr
+		}
+
+		path := filepath.ToSlash(rel)
+
+		expiresAt, err := b.readExpiration(path)
+		if err != nil || expiresAt.IsZero() || expiresAt.After(now) {
+			return nil // nolint:nilerr
+		}
+
+		err = b.Remove(b.pathToCLink(path))
+		if errors.Is(err, os.ErrNotExist) {
+			// the file is removed by hand, only the index entry is left
+			err = b.writeExpiration(path, time.Time{})
+		}
+		if err != nil {
+			log.Println("Failed to remove expired file:", path, err)
+			return nil
+		}
+
+		removed++
+
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to scan TTL index: %w", err)
+	}
+
+	return removed, nil
+}
+
+// Close stops the janitor and waits for the running scan
+func (b *Local) Close() error {
+	if b.stop == nil {
+		return nil
+	}
+
+	b.closeOnce.Do(func() {
+		close(b.stop)
+	})
+	<-b.done
+
+	return nil
+}
+
+func (b *Local) janitor(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			if _, err := b.Expire(); err != nil {
+				log.Println("Janitor:", err)
+			}
+
+			if b.cfg.Trash {
+				if _, err := b.PurgeTrash(); err != nil {
+					log.Println("Janitor:", err)
+				}
+			}
+		}
+	}
+}
+
+func (b *Local) ttlPath(path string) string {
+	return b.pathToInternalPath(ttlDir + "/" + path)
+}
+
+// readExpiration returns zero time if the object is not temporary
+func (b *Local) readExpiration(path string) (expiresAt time.Time, err error) {
+	data, err := os.ReadFile(b.ttlPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return expiresAt, nil
+	}
+	if err != nil {
+		return expiresAt, fmt.Errorf("failed to read expiration: %w", err)
+	}
+
+	expiresAt, err = time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return expiresAt, fmt.Errorf("failed to parse expiration: %w", err)
+	}
+
+	return expiresAt, nil
+}
+
+// writeExpiration adds the object to the TTL index, zero time removes it
+func (b *Local) writeExpiration(path string, expiresAt time.Time) error {
+	ttlPath := b.ttlPath(path)
+
+	if expiresAt.IsZero() {
+		err := os.Remove(ttlPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove expiration: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ttlPath), mkdirPerm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.WriteFile(ttlPath, []byte(expiresAt.UTC().Format(time.RFC3339Nano)), filePerm); err != nil {
+		return fmt.Errorf("failed to write expiration: %w", err)
+	}
+
+	return nil
+}
+
+func checkExpiration(expiresAt time.Time) error {
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: %s is in the past", common.ErrInvalidExpiration, expiresAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package local
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// Handler serves files by URLs of GetURL: GET and HEAD with Range, ETag/If-None-Match,
+// Last-Modified/If-Modified-Since and the stored headers.
+// The path of Endpoint is stripped from the request path, so the handler can be mounted with or without
+// http.StripPrefix. Internal directories, paths outside Root and expired files are not found.
+// With SigningKey signed URLs are verified and PUT by LinkForPutObject URLs stores the request body
+// up to MaxUploadSize by the storage of SetUploadStorage
+func (b *Local) Handler() http.Handler {
+	return http.HandlerFunc(b.serveHTTP)
+}
+
+func (b *Local) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+	case r.Method == http.MethodPut && b.cfg.SigningKey != "":
+	default:
+		allow := "GET, HEAD"
+		if b.cfg.SigningKey != "" {
+			allow += ", PUT"
+		}
+
+		w.Header().Set("Allow", allow)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	path, internalPath, err := b.requestPath(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err = b.authorize(r, path); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		b.serveUpload(w, r, path)
+		return
+	}
+
+	f, err := os.Open(internalPath)
+	if err != nil {
+		b.httpError(w, r, err)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	metaPath := path
+
+	if internalPath == b.pathToInternalPath(path) {
+		// the janitor removes expired files with a delay
+		expiresAt, err := b.readExpiration(path)
+		if err != nil {
+			b.httpError(w, r, err)
+			return
+		}
+
+		if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+			http.NotFound(w, r)
+			return
+		}
+	} else {
+		// sidecar of the version is moved with it
+		metaPath = b.internalPathToPath(internalPath)
+	}
+
+	m, err := b.readMeta(metaPath)
+	if err != nil {
+		b.httpError(w, r, err)
+		return
+	}
+
+	h := w.Header()
+	h.Set("ETag", fileETag(fi))
+
+	for name, value := range map[string]string{
+		"Content-Type":        m.ContentType,
+		"Content-Encoding":    m.ContentEncoding,
+		"Cache-Control":       m.CacheControl,
+		"Content-Disposition": m.ContentDisposition,
+		"Content-Language":    m.ContentLanguage,
+	} {
+		if value != "" {
+			h.Set(name, value)
+		}
+	}
+
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+// requestPath returns path and internal path of the file or its version requested by ?versionId=
+func (b *Local) requestPath(r *http.Request) (path, internalPath string, err error) {
+	path = r.URL.Path
+
+	if u, err := url.Parse(b.cfg.Endpoint); err == nil && strings.Trim(u.Path, "/") != "" {
+		path = strings.TrimPrefix(strings.TrimLeft(path, "/"), strings.Trim(u.Path, "/")+"/")
+	}
+
+	if strings.Trim(path, "/") == "" {
+		return "", "", common.ErrInvalidPath
+	}
+
+	path, internalPath, err = b.safeInternalPath(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	if id := r.URL.Query().Get(versionQuery); id != "" {
+		if internalPath, err = b.versionInternalPath(path, id); err != nil {
+			return "", "", err
+		}
+	}
+
+	return path, internalPath, nil
+}
+
+// authorize verifies signed URLs, PUT and all requests with SignedOnly should be signed
+func (b *Local) authorize(r *http.Request, path string) error {
+	query := r.URL.Query()
+
+	if r.Method != http.MethodPut && !b.cfg.SignedOnly && query.Get(signatureQuery) == "" {
+		return nil
+	}
+
+	return b.Verify(r.Method, path, query)
+}
+
+// serveUpload stores the request body by the upload storage, Content-Type header is saved as the content type
+func (b *Local) serveUpload(w http.ResponseWriter, r *http.Request, path string) {
+	maxSize := b.cfg.MaxUploadSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxUploadSize
+	}
+
+	if r.ContentLength > maxSize {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	f, err := os.CreateTemp("", "local-upload-*")
+	if err != nil {
+		b.httpError(w, r, err)
+		return
+	}
+	defer os.Remove(f.Name())
+
+	n, err := io.Copy(f, http.MaxBytesReader(w, r.Body, maxSize))
+	f.Close()
+
+	switch {
+	case err != nil && n >= maxSize:
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	var options []interface{}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		options = append(options, core.ContentType(ct))
+	}
+
+	storage := b.upload
+	if storage == nil {
+		storage = b
+	}
+
+	if err = storage.StoreByCLink(f.Name(), b.pathToCLink(path), options...); err != nil {
+		b.httpError(w, r, err)
+		return
+	}
+
+	if fi, err := os.Stat(b.pathToInternalPath(path)); err == nil {
+		w.Header().Set("ETag", fileETag(fi))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (b *Local) httpError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, core.ErrObjectNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	log.Println("Failed to serve file:", r.URL.Path, err)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -3,9 +3,18 @@ package local
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
 )
 
 type (
@@ -13,11 +22,39 @@ type (
 		StorageKey string
 		Endpoint   string
 		Root       string
-		BufferSize int // bytes
+		BufferSize int                // bytes
+		PathPolicy *common.PathPolicy // default common.DefaultPathPolicy
+
+		// files stored with core.ExpiresAt are removed every JanitorInterval,
+		// 0 - the janitor is not started, call Expire manually
+		JanitorInterval time.Duration
+
+		// Remove moves files to Root/.trash, they are purged after TrashRetention
+		// (default common.DefaultTrashRetention) by PurgeTrash or the janitor
+		Trash          bool
+		TrashRetention time.Duration
+
+		// Store and Remove keep previous files in Root/.versions, see ListVersions
+		Versioning bool
+
+		// secret of signed URLs: GetURL signs them with core.ExpirationVerifier or LinkForPutObject option
+		// and Handler verifies them. With SignedOnly Handler does not serve unsigned URLs,
+		// so GetURL signs all of them, for an hour by default
+		SigningKey string
+		SignedOnly bool
+
+		// limit of PUT request body in Handler, default 100 MB
+		MaxUploadSize int64
 	}
 
 	Local struct {
-		cfg Config
+		cfg    Config
+		policy common.PathPolicy
+		upload core.Storage
+
+		stop      chan struct{}
+		done      chan struct{}
+		closeOnce sync.Once
 	}
 )
 
@@ -26,6 +63,8 @@ const (
 	defaultEndpoint   = "http://localhost:8080/"
 	defaultRoot       = ""
 	defaultBufferSize = 16 * 1024
+
+	defaultMaxUploadSize = 100 << 20
 )
 
 var (
@@ -43,19 +82,39 @@ func New(cfg *Config) *Local {
 		}
 	}
 
-	return &Local{
-		cfg: *cfg,
+	// backslash is a path separator on Windows
+	policy := common.GetPathPolicy(cfg.PathPolicy)
+	policy.ForbiddenChars += `\`
+
+	b := &Local{
+		cfg:    *cfg,
+		policy: policy,
+	}
+
+	if cfg.JanitorInterval > 0 {
+		b.stop = make(chan struct{})
+		b.done = make(chan struct{})
+
+		go b.janitor(cfg.JanitorInterval)
 	}
+
+	return b
+}
+
+// SetUploadStorage sets the storage Handler stores PUT uploads by, e.g. Local wrapped by quota or validation.
+// By default uploads are stored by Local itself
+func (b *Local) SetUploadStorage(s core.Storage) {
+	b.upload = s
 }
 
-func (b *Local) Store(filePath, path string) (cLink string, err error) {
-	return b.storeByPath(filePath, path)
+func (b *Local) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	return b.storeByPath(filePath, path, core.ParseStoreOptions(options...))
 }
 
-func (b *Local) StoreByCLink(filePath, cLink string) (err error) {
+func (b *Local) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
 	path := b.cLinkToPath(cLink)
 
-	_, err = b.storeByPath(filePath, path)
+	_, err = b.storeByPath(filePath, path, core.ParseStoreOptions(options...))
 
 	return
 }
@@ -72,6 +131,34 @@ func (b *Local) GetURL(cLink string, options ...interface{}) string {
 		return ""
 	}
 
+	// the version is selected by query parameter, as in s3 URLs
+	if id := core.ParseVersion(options...); id != "" {
+		u.RawQuery = url.Values{versionQuery: {id}}.Encode()
+	}
+
+	// without the key URLs are not signed as before, but upload needs the signature
+	method, expires := parseURLOptions(cLink, options...)
+	if b.cfg.SigningKey == "" && method == http.MethodPut {
+		log.Println("Failed to sign URL:", cLink, ErrSigningKeyRequired)
+		return ""
+	}
+
+	if expires.IsZero() && b.cfg.SignedOnly {
+		expires = time.Now().Add(getLinkLifeTime)
+	}
+
+	if b.cfg.SigningKey == "" || expires.IsZero() {
+		return u.String()
+	}
+
+	path, err := b.policy.Normalize(b.cLinkToPath(cLink))
+	if err != nil {
+		log.Println("Invalid path:", err)
+		return ""
+	}
+
+	b.signURL(u, method, path, expires)
+
 	return u.String()
 }
 
@@ -81,9 +168,25 @@ func (b *Local) Remove(cLink string) (err error) {
 		return ErrFailedGetFilePath
 	}
 
-	internalPath := b.pathToInternalPath(path)
-	if internalPath == "" {
-		return ErrFailedGetFilePath
+	path, internalPath, err := b.safeInternalPath(path)
+	if err != nil {
+		return err
+	}
+
+	if b.cfg.Trash {
+		return b.trash(path)
+	}
+
+	if b.cfg.Versioning {
+		if _, err = os.Stat(internalPath); err != nil {
+			return fmt.Errorf("failed to remove file: %w", err)
+		}
+
+		if err = b.keepVersion(path); err != nil {
+			return err
+		}
+
+		return b.writeExpiration(path, time.Time{})
 	}
 
 	err = os.Remove(internalPath)
@@ -91,9 +194,176 @@ func (b *Local) Remove(cLink string) (err error) {
 		return fmt.Errorf("failed to remove file: %w", err)
 	}
 
-	return nil
+	if err = b.writeExpiration(path, time.Time{}); err != nil {
+		return err
+	}
+
+	return b.writeMeta(path, metadata{})
 }
 
 func (b *Local) GetCLink(path string) (cLink string) {
+	path, err := b.policy.Normalize(path)
+	if err != nil {
+		log.Println("Invalid path:", err)
+		return ""
+	}
+
 	return b.pathToCLink(path)
 }
+
+func (b *Local) Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	path := b.cLinkToPath(cLink)
+	if path == "" {
+		return nil, ErrFailedGetFilePath
+	}
+
+	path, internalPath, err := b.safeInternalPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if id := core.ParseVersion(options...); id != "" {
+		if internalPath, err = b.versionInternalPath(path, id); err != nil {
+			return nil, fmt.Errorf("%s: %w", cLink, err)
+		}
+	}
+
+	f, err := os.Open(internalPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return f, nil
+}
+
+// OpenRange opens the file and seeks to offset, length < 0 - to the end
+func (b *Local) OpenRange(cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error) {
+	rc, err = b.Open(cLink, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = rc.(io.Seeker).Seek(offset, io.SeekStart); err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("failed to seek: %w", err)
+	}
+
+	return core.LimitReadCloser(rc, length), nil
+}
+
+func (b *Local) Stat(cLink string) (info core.ObjectInfo, err error) {
+	path := b.cLinkToPath(cLink)
+	if path == "" {
+		return info, ErrFailedGetFilePath
+	}
+
+	path, internalPath, err := b.safeInternalPath(path)
+	if err != nil {
+		return info, err
+	}
+
+	fi, err := os.Stat(internalPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return info, fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return info, fmt.Errorf("failed to stat: %w", err)
+	}
+
+	if !fi.Mode().IsRegular() {
+		return info, fmt.Errorf("%s: %w", cLink, ErrFileNotRegular)
+	}
+
+	m, err := b.readMeta(path)
+	if err != nil {
+		return info, err
+	}
+
+	expiresAt, err := b.readExpiration(path)
+	if err != nil {
+		return info, err
+	}
+
+	info = core.ObjectInfo{
+		CLink:        b.pathToCLink(path),
+		Path:         path,
+		Size:         fi.Size(),
+		ETag:         fileETag(fi),
+		LastModified: fi.ModTime(),
+		ExpiresAt:    expiresAt,
+	}
+
+	if m.ContentType == "" {
+		f, err := os.Open(internalPath)
+		if err != nil {
+			return info, fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+
+		info.ContentType = common.GetFileContentType(f)
+	}
+
+	b.applyMeta(&info, m)
+
+	return info, nil
+}
+
+// UpdateMetadata changes headers and user metadata in the sidecar file, see core.MergeMetadata
+func (b *Local) UpdateMetadata(cLink string, options ...interface{}) (err error) {
+	info, err := b.Stat(cLink)
+	if err != nil {
+		return err
+	}
+
+	return b.writeMeta(info.Path, newMetadata(core.MergeMetadata(info, options...)))
+}
+
+func (b *Local) List(prefix string, fn func(info core.ObjectInfo) error) (err error) {
+	if prefix, err = b.normalizePrefix(prefix); err != nil {
+		return err
+	}
+
+	dir := b.pathToInternalPath(prefix)
+	if !strings.HasSuffix(prefix, "/") {
+		dir = filepath.Dir(dir)
+	}
+
+	err = filepath.Walk(dir, func(internalPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		path, err := b.relativePath(internalPath)
+		if err != nil {
+			return nil // nolint:nilerr
+		}
+
+		if fi.IsDir() && isReserved(path) {
+			return filepath.SkipDir
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		if !strings.HasPrefix(path, prefix) {
+			return nil
+		}
+
+		return fn(core.ObjectInfo{
+			CLink:        b.pathToCLink(path),
+			Path:         path,
+			Size:         fi.Size(),
+			ETag:         fileETag(fi),
+			LastModified: fi.ModTime(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	return nil
+}
@@ -1,10 +1,17 @@
 package local
 
 import (
+	"errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
 )
 
 var (
@@ -270,3 +277,616 @@ func TestRemove(t *testing.T) {
 	os.Remove(tmp)
 	os.RemoveAll(testRoot)
 }
+
+func TestStat(t *testing.T) {
+	tmp := "sfile1"
+	ioutil.WriteFile(tmp, []byte("hello\ngo\n"), 0o644)
+	cLink, _ := testStorage.Store(tmp, "/s_test/sfile.txt")
+
+	info, err := testStorage.Stat(cLink)
+	if err != nil {
+		t.Errorf("Stat err: %q", err)
+	}
+
+	if info.Size != 9 || info.Path != "s_test/sfile.txt" || info.ETag == "" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	_, err = testStorage.Stat(testStorageKey + ":s_test/missing.txt")
+	if !errors.Is(err, core.ErrObjectNotFound) {
+		t.Errorf("got %v, want %v", err, core.ErrObjectNotFound)
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestMetadata(t *testing.T) {
+	tmp := "mfile1"
+	ioutil.WriteFile(tmp, []byte("hello\ngo\n"), 0o644)
+	cLink, _ := testStorage.Store(tmp, "/m_test/mfile.txt",
+		core.ContentType("text/markdown"),
+		core.CacheControl("max-age=60"),
+		core.UserMetadata{"Owner": "42", "Source": "test"},
+	)
+
+	info, err := testStorage.Stat(cLink)
+	if err != nil {
+		t.Errorf("Stat err: %q", err)
+	}
+
+	if info.ContentType != "text/markdown" || info.CacheControl != "max-age=60" || info.Metadata["owner"] != "42" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	err = testStorage.UpdateMetadata(cLink, core.ContentDisposition("attachment"), core.UserMetadata{"source": ""})
+	if err != nil {
+		t.Errorf("UpdateMetadata err: %q", err)
+	}
+
+	info, _ = testStorage.Stat(cLink)
+	if info.ContentDisposition != "attachment" || info.CacheControl != "max-age=60" ||
+		info.Metadata["owner"] != "42" || len(info.Metadata) != 1 {
+		t.Errorf("unexpected info after update: %+v", info)
+	}
+
+	// store without options drops the old metadata
+	testStorage.StoreByCLink(tmp, cLink)
+
+	info, _ = testStorage.Stat(cLink)
+	if info.CacheControl != "" || info.Metadata != nil {
+		t.Errorf("metadata is not removed: %+v", info)
+	}
+
+	// internal directories are not available
+	if _, err = testStorage.Store(tmp, ".meta/m_test/mfile.txt.json"); !errors.Is(err, common.ErrInvalidPath) {
+		t.Errorf("got %v, want %v", err, common.ErrInvalidPath)
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestExpiration(t *testing.T) {
+	tmp := "efile1"
+	ioutil.WriteFile(tmp, []byte("draft"), 0o644)
+
+	_, err := testStorage.Store(tmp, "/e_test/old.txt", core.ExpiresAt(time.Now().Add(-time.Second)))
+	if !errors.Is(err, common.ErrInvalidExpiration) {
+		t.Errorf("got %v, want %v", err, common.ErrInvalidExpiration)
+	}
+
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	cLink, _ := testStorage.Store(tmp, "/e_test/draft.txt", core.ExpiresAt(expiresAt))
+	keep, _ := testStorage.Store(tmp, "/e_test/keep.txt")
+
+	info, _ := testStorage.Stat(cLink)
+	if !info.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("got expiration %v, want %v", info.ExpiresAt, expiresAt)
+	}
+
+	if removed, _ := testStorage.Expire(); removed != 0 {
+		t.Errorf("removed %d files before expiration", removed)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if removed, err := testStorage.Expire(); removed != 1 || err != nil {
+		t.Errorf("removed %d files, err %v, want 1", removed, err)
+	}
+
+	if _, err = testStorage.Stat(cLink); !errors.Is(err, core.ErrObjectNotFound) {
+		t.Errorf("got %v, want %v", err, core.ErrObjectNotFound)
+	}
+
+	if _, err = testStorage.Stat(keep); err != nil {
+		t.Errorf("Stat err: %q", err)
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestJanitor(t *testing.T) {
+	cfg := *testCfg
+	cfg.JanitorInterval = 10 * time.Millisecond
+	s := New(&cfg)
+
+	tmp := "jfile1"
+	ioutil.WriteFile(tmp, []byte("draft"), 0o644)
+	cLink, _ := s.Store(tmp, "/j_test/draft.txt", core.ExpiresAt(time.Now().Add(20*time.Millisecond)))
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := s.Stat(cLink); !errors.Is(err, core.ErrObjectNotFound) {
+		t.Errorf("got %v, want %v", err, core.ErrObjectNotFound)
+	}
+
+	if err := core.Close(s); err != nil {
+		t.Errorf("Close err: %q", err)
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestTrash(t *testing.T) {
+	cfg := *testCfg
+	cfg.Trash = true
+	cfg.TrashRetention = time.Hour
+	s := New(&cfg)
+
+	tmp := "tfile1"
+	ioutil.WriteFile(tmp, []byte("invoice"), 0o644)
+	cLink, _ := s.Store(tmp, "/t_test/invoice.txt", core.ContentType("text/markdown"))
+
+	if err := s.Remove(cLink); err != nil {
+		t.Errorf("Remove err: %q", err)
+	}
+
+	if _, err := s.Stat(cLink); !errors.Is(err, core.ErrObjectNotFound) {
+		t.Errorf("got %v, want %v", err, core.ErrObjectNotFound)
+	}
+
+	// trash is not listed and not available by path
+	s.List("", func(info core.ObjectInfo) error {
+		t.Errorf("unexpected object: %q", info.Path)
+		return nil
+	})
+
+	if _, err := s.Stat(testStorageKey + ":.trash/t_test/invoice.txt"); !errors.Is(err, common.ErrInvalidPath) {
+		t.Errorf("got %v, want %v", err, common.ErrInvalidPath)
+	}
+
+	if err := s.Restore(cLink); err != nil {
+		t.Errorf("Restore err: %q", err)
+	}
+
+	info, err := s.Stat(cLink)
+	if err != nil || info.ContentType != "text/markdown" {
+		t.Errorf("unexpected info: %+v, err %v", info, err)
+	}
+
+	if err = s.Restore(cLink); !errors.Is(err, core.ErrObjectNotFound) {
+		t.Errorf("got %v, want %v", err, core.ErrObjectNotFound)
+	}
+
+	// restore does not overwrite new object
+	s.Remove(cLink)
+	s.Store(tmp, "/t_test/invoice.txt")
+
+	if err = s.Restore(cLink); !errors.Is(err, core.ErrObjectExists) {
+		t.Errorf("got %v, want %v", err, core.ErrObjectExists)
+	}
+
+	// every removal is kept, the latest one is restored
+	ioutil.WriteFile(tmp, []byte("invoice v3"), 0o644)
+	s.Remove(cLink)
+	s.Store(tmp, "/t_test/invoice.txt")
+	s.Remove(cLink)
+
+	if err = s.Restore(cLink); err != nil {
+		t.Errorf("Restore err: %q", err)
+	}
+
+	if info, _ = s.Stat(cLink); info.Size != 10 {
+		t.Errorf("restored %d bytes, want the latest removed object", info.Size)
+	}
+
+	if purged, _ := s.PurgeTrash(); purged != 0 {
+		t.Errorf("purged %d files before retention", purged)
+	}
+
+	s.cfg.TrashRetention = time.Nanosecond
+
+	if purged, _ := s.PurgeTrash(); purged != 2 {
+		t.Errorf("purged %d files, want 2", purged)
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestVersions(t *testing.T) {
+	cfg := *testCfg
+	cfg.Versioning = true
+	s := New(&cfg)
+
+	tmp := "vfile1"
+	ioutil.WriteFile(tmp, []byte("v1"), 0o644)
+	cLink, _ := s.Store(tmp, "/v_test/doc.txt", core.ContentType("text/markdown"))
+
+	time.Sleep(time.Millisecond)
+	ioutil.WriteFile(tmp, []byte("v2 longer"), 0o644)
+	s.Store(tmp, "/v_test/doc.txt")
+
+	versions, err := s.ListVersions(cLink)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("got %d versions, err %v, want 2", len(versions), err)
+	}
+
+	latest, first := versions[0], versions[1]
+	if !latest.IsLatest || latest.Size != 9 || first.IsLatest || first.Size != 2 || first.ContentType != "text/markdown" {
+		t.Errorf("unexpected versions: %+v", versions)
+	}
+
+	if u := s.GetURL(cLink, core.WithVersion(first.VersionID)); u != testEndpoint+"/v_test/doc.txt?versionId="+first.VersionID {
+		t.Errorf("unexpected URL: %q", u)
+	}
+
+	rc, err := s.Open(cLink, core.WithVersion(first.VersionID))
+	if err != nil {
+		t.Fatalf("Open err: %q", err)
+	}
+
+	data, _ := ioutil.ReadAll(rc)
+	rc.Close()
+
+	if string(data) != "v1" {
+		t.Errorf("got %q, want %q", data, "v1")
+	}
+
+	if err = s.RestoreVersion(cLink, first.VersionID); err != nil {
+		t.Errorf("RestoreVersion err: %q", err)
+	}
+
+	info, _ := s.Stat(cLink)
+	if info.Size != 2 || info.ContentType != "text/markdown" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	if versions, _ = s.ListVersions(cLink); len(versions) != 3 {
+		t.Errorf("got %d versions, want 3", len(versions))
+	}
+
+	if err = s.RestoreVersion(cLink, "../../x"); !errors.Is(err, core.ErrObjectNotFound) {
+		t.Errorf("got %v, want %v", err, core.ErrObjectNotFound)
+	}
+
+	// removed file is kept as version
+	s.Remove(cLink)
+
+	if versions, _ = s.ListVersions(cLink); len(versions) != 3 || versions[0].IsLatest {
+		t.Errorf("unexpected versions after remove: %+v", versions)
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestRemovePrefix(t *testing.T) {
+	s := testStorage
+
+	tmp := "bfile1"
+	ioutil.WriteFile(tmp, []byte("batch"), 0o644)
+
+	a, _ := s.Store(tmp, "/b_test/a.txt")
+	b, _ := s.Store(tmp, "/b_test/sub/b.txt")
+	c, _ := s.Store(tmp, "/b_test2/c.txt")
+
+	results := s.RemoveMany([]string{a, testStorageKey + ":b_test/missing.txt"})
+	if len(results) != 2 || results[0].CLink != a || results[0].Err != nil || results[1].Err == nil {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	if _, err := s.RemovePrefix("/"); !errors.Is(err, core.ErrEmptyPrefix) {
+		t.Errorf("got %v, want %v", err, core.ErrEmptyPrefix)
+	}
+
+	if _, err := s.RemovePrefix("../"); !errors.Is(err, common.ErrPathTraversal) {
+		t.Errorf("got %v, want %v", err, common.ErrPathTraversal)
+	}
+
+	results, err := s.RemovePrefix("b_test/")
+	if err != nil || len(results) != 1 || results[0].CLink != b || results[0].Err != nil {
+		t.Errorf("unexpected results: %+v, err %v", results, err)
+	}
+
+	// the tree is removed, neighbours are kept
+	if _, err = os.Stat(testRoot + "/b_test"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory is not removed: %v", err)
+	}
+
+	if _, err = s.Stat(c); err != nil {
+		t.Errorf("Stat err: %q", err)
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestList(t *testing.T) {
+	tmp := "lfile1"
+	ioutil.WriteFile(tmp, []byte("hello\ngo\n"), 0o644)
+
+	for _, path := range []string{"l_test/a.txt", "l_test/sub/b.txt", "l_test2/c.txt"} {
+		testStorage.Store(tmp, path)
+	}
+
+	flagtests := []struct {
+		in  string
+		out int
+	}{
+		{"l_test/", 2},
+		{"l_test", 3},
+		{"l_test/sub", 1},
+		{"missing/", 0},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.in, func(t *testing.T) {
+			var count int
+
+			err := testStorage.List(tt.in, func(info core.ObjectInfo) error {
+				if info.CLink != testStorage.pathToCLink(info.Path) {
+					t.Errorf("unexpected info: %+v", info)
+				}
+				count++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("List err: %q", err)
+			}
+
+			if count != tt.out {
+				t.Errorf("got %d, want %d", count, tt.out)
+			}
+		})
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestPathPolicy(t *testing.T) {
+	flagtests := []struct {
+		in  string
+		out string
+		err error
+	}{
+		{"folder//file1.jpg", testStorageKey + ":" + "folder/file1.jpg", nil},
+		{"café.jpg", testStorageKey + ":" + "café.jpg", nil},
+		{"../../etc/x", "", common.ErrPathTraversal},
+		{"folder/../../x", "", common.ErrPathTraversal},
+		{"folder/./x", "", common.ErrPathTraversal},
+		{"file\n1.jpg", "", common.ErrInvalidPath},
+		{"folder\\..\\x", "", common.ErrInvalidPath},
+		{"/", "", common.ErrInvalidPath},
+	}
+
+	tmp := "pfile1"
+	ioutil.WriteFile(tmp, []byte("hello\ngo\n"), 0o644)
+
+	for _, tt := range flagtests {
+		t.Run(tt.in, func(t *testing.T) {
+			cLink, err := testStorage.Store(tmp, tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+
+			if cLink != tt.out {
+				t.Errorf("got %q, want %q", cLink, tt.out)
+			}
+
+			if tt.err != nil && testStorage.GetCLink(tt.in) != "" {
+				t.Errorf("GetCLink should reject %q", tt.in)
+			}
+		})
+	}
+
+	_, err := testStorage.Open(testStorageKey + ":../local_test.go")
+	if !errors.Is(err, common.ErrPathTraversal) {
+		t.Errorf("got %v, want %v", err, common.ErrPathTraversal)
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+func TestHandler(t *testing.T) {
+	s := testStorage
+	h := s.Handler()
+
+	tmp := "hfile1"
+	ioutil.WriteFile(tmp, []byte("0123456789"), 0o644)
+	s.Store(tmp, "/h_test/file.txt", core.ContentType("text/csv"), core.CacheControl("max-age=60"))
+	s.Store(tmp, "/h_test/expired.txt", core.ExpiresAt(time.Now().Add(time.Hour)))
+	s.writeExpiration("h_test/expired.txt", time.Now().Add(-time.Second))
+
+	get := func(method, target string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := get(http.MethodGet, "/files/h_test/file.txt", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" ||
+		w.Header().Get("Content-Type") != "text/csv" || w.Header().Get("Cache-Control") != "max-age=60" {
+		t.Errorf("unexpected response: %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	etag := w.Header().Get("ETag")
+
+	flagtests := []struct {
+		name   string
+		method string
+		target string
+		header map[string]string
+		code   int
+		body   string
+	}{
+		{"without endpoint path", http.MethodGet, "/h_test/file.txt", nil, http.StatusOK, "0123456789"},
+		{"range", http.MethodGet, "/files/h_test/file.txt", map[string]string{"Range": "bytes=2-4"}, http.StatusPartialContent, "234"},
+		{"etag", http.MethodGet, "/files/h_test/file.txt", map[string]string{"If-None-Match": etag}, http.StatusNotModified, ""},
+		{"head", http.MethodHead, "/files/h_test/file.txt", nil, http.StatusOK, ""},
+		{"post", http.MethodPost, "/files/h_test/file.txt", nil, http.StatusMethodNotAllowed, ""},
+		{"missing", http.MethodGet, "/files/h_test/missing.txt", nil, http.StatusNotFound, ""},
+		{"directory", http.MethodGet, "/files/h_test", nil, http.StatusNotFound, ""},
+		{"traversal", http.MethodGet, "/files/h_test/../../local.go", nil, http.StatusNotFound, ""},
+		{"reserved", http.MethodGet, "/files/.meta/h_test/file.txt.json", nil, http.StatusNotFound, ""},
+		{"expired", http.MethodGet, "/files/h_test/expired.txt", nil, http.StatusNotFound, ""},
+		{"bad version", http.MethodGet, "/files/h_test/file.txt?versionId=zz", nil, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.method, tt.target, tt.header)
+			if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+				t.Errorf("got %d %q, want %d %q", w.Code, w.Body.String(), tt.code, tt.body)
+			}
+		})
+	}
+
+	// clear
+	os.Remove(tmp)
+	os.RemoveAll(testRoot)
+}
+
+type testExpiration time.Time
+
+func (e testExpiration) GetAccessExpireTime(cLink string) time.Time {
+	return time.Time(e)
+}
+
+func TestSignedURL(t *testing.T) {
+	cfg := *testCfg
+	cfg.SigningKey = "secret"
+	cfg.SignedOnly = true
+	s := New(&cfg)
+	h := s.Handler()
+
+	do := func(method, rawURL, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, rawURL, strings.NewReader(body))
+		r.Header.Set("Content-Type", "text/plain")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	cLink := testStorageKey + ":s_test/file.txt"
+
+	putURL := s.GetURL(cLink, LinkForPutObject)
+	if w := do(http.MethodPut, putURL, "signed"); w.Code != http.StatusOK {
+		t.Fatalf("PUT: %d %q", w.Code, w.Body.String())
+	}
+
+	if info, err := s.Stat(cLink); err != nil || info.ContentType != "text/plain" {
+		t.Errorf("unexpected info: %+v, err %v", info, err)
+	}
+
+	getURL := s.GetURL(cLink, testExpiration(time.Now().Add(time.Minute)))
+	expiredURL := s.GetURL(cLink, testExpiration(time.Now().Add(-time.Minute)))
+
+	flagtests := []struct {
+		name   string
+		method string
+		url    string
+		code   int
+	}{
+		{"signed", http.MethodGet, getURL, http.StatusOK},
+		{"head", http.MethodHead, getURL, http.StatusOK},
+		{"signed by default", http.MethodGet, s.GetURL(cLink), http.StatusOK},
+		{"unsigned", http.MethodGet, testStorage.GetURL(cLink), http.StatusForbidden},
+		{"expired", http.MethodGet, expiredURL, http.StatusForbidden},
+		{"tampered", http.MethodGet, strings.Replace(getURL, "file.txt", "other.txt", 1), http.StatusForbidden},
+		{"get by put URL", http.MethodGet, putURL, http.StatusForbidden},
+		{"put by get URL", http.MethodPut, getURL, http.StatusForbidden},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(tt.method, tt.url, ""); w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+
+	// without the key URLs are not signed and upload is not available
+	if u := testStorage.GetURL(cLink, testExpiration(time.Now())); strings.Contains(u, "Signature") {
+		t.Errorf("unexpected signed URL %q", u)
+	}
+
+	if u := testStorage.GetURL(cLink, LinkForPutObject); u != "" {
+		t.Errorf("unexpected put URL %q", u)
+	}
+
+	// clear
+	os.RemoveAll(testRoot)
+}
+
+type uploadStorage struct {
+	*Local
+	stored []string
+}
+
+func (s *uploadStorage) StoreByCLink(filePath, cLink string, options ...interface{}) error {
+	s.stored = append(s.stored, cLink)
+	return s.Local.StoreByCLink(filePath, cLink, options...)
+}
+
+func TestUpload(t *testing.T) {
+	cfg := *testCfg
+	cfg.SigningKey = "secret"
+	cfg.MaxUploadSize = 10
+	s := New(&cfg)
+
+	upload := &uploadStorage{Local: s}
+	s.SetUploadStorage(upload)
+
+	flagtests := []struct {
+		name   string
+		path   string
+		body   string
+		code   int
+		stored bool
+	}{
+		{"small", "u_test/small.txt", "0123456789", http.StatusOK, true},
+		{"large", "u_test/large.txt", "0123456789a", http.StatusRequestEntityTooLarge, false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			upload.stored = nil
+			cLink := testStorageKey + ":" + tt.path
+
+			r := httptest.NewRequest(http.MethodPut, s.GetURL(cLink, LinkForPutObject), strings.NewReader(tt.body))
+			// unknown length is limited while reading
+			r.ContentLength = -1
+
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("got %d, want %d", w.Code, tt.code)
+			}
+
+			if stored := len(upload.stored) == 1 && upload.stored[0] == cLink; stored != tt.stored {
+				t.Errorf("stored by the upload storage %v, want %v", upload.stored, tt.stored)
+			}
+
+			if _, err := s.Stat(cLink); (err == nil) != tt.stored {
+				t.Errorf("unexpected Stat err %v", err)
+			}
+		})
+	}
+
+	// clear
+	os.RemoveAll(testRoot)
+}
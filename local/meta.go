@@ -0,0 +1,116 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// metaDir keeps sidecar files with metadata of the objects: Root/.meta/<path>.json
+const metaDir = ".meta"
+
+// reservedDirs are internal directories in Root, not available for user paths
+var reservedDirs = map[string]bool{
+	metaDir:         true,
+	ttlDir:          true,
+	common.TrashDir: true,
+	versionsDir:     true,
+}
+
+type metadata struct {
+	ContentType        string            `json:"content_type,omitempty"`
+	ContentEncoding    string            `json:"content_encoding,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	ContentLanguage    string            `json:"content_language,omitempty"`
+	UserMetadata       map[string]string `json:"user_metadata,omitempty"`
+}
+
+func newMetadata(o core.StoreOptions) metadata {
+	return metadata{
+		ContentType:        o.ContentType,
+		ContentEncoding:    o.ContentEncoding,
+		CacheControl:       o.CacheControl,
+		ContentDisposition: o.ContentDisposition,
+		ContentLanguage:    o.ContentLanguage,
+		UserMetadata:       o.UserMetadata,
+	}
+}
+
+func (m metadata) isEmpty() bool {
+	return m.ContentType == "" && m.ContentEncoding == "" && m.CacheControl == "" &&
+		m.ContentDisposition == "" && m.ContentLanguage == "" && len(m.UserMetadata) == 0
+}
+
+// isReserved reports whether path is inside one of the internal directories
+func isReserved(path string) bool {
+	return reservedDirs[strings.SplitN(strings.TrimLeft(path, "/"), "/", 2)[0]]
+}
+
+func (b *Local) metaPath(path string) string {
+	return b.pathToInternalPath(metaDir + "/" + path + ".json")
+}
+
+// readMeta returns empty metadata if the object has no sidecar
+func (b *Local) readMeta(path string) (m metadata, err error) {
+	data, err := os.ReadFile(b.metaPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	if err = json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	return m, nil
+}
+
+// writeMeta replaces the sidecar, empty metadata removes it
+func (b *Local) writeMeta(path string, m metadata) error {
+	metaPath := b.metaPath(path)
+
+	if m.isEmpty() {
+		err := os.Remove(metaPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove metadata: %w", err)
+		}
+
+		return nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(metaPath), mkdirPerm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err = os.WriteFile(metaPath, data, filePerm); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Local) applyMeta(info *core.ObjectInfo, m metadata) {
+	if m.ContentType != "" {
+		info.ContentType = m.ContentType
+	}
+
+	info.ContentEncoding = m.ContentEncoding
+	info.CacheControl = m.CacheControl
+	info.ContentDisposition = m.ContentDisposition
+	info.ContentLanguage = m.ContentLanguage
+	info.Metadata = m.UserMetadata
+}
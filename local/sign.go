@@ -0,0 +1,105 @@
+package local
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	LocalOption int
+)
+
+const (
+	// LinkForPutObject - GetURL option, signed URL to upload the file by PUT request to Handler
+	LinkForPutObject LocalOption = iota + 1
+)
+
+const (
+	putLinkLifeTime = 30 * time.Minute
+	getLinkLifeTime = time.Hour // with SignedOnly unsigned URLs are forbidden
+
+	expiresQuery   = "Expires"
+	signatureQuery = "Signature"
+)
+
+var (
+	ErrSigningKeyRequired = errors.New("signing key is required")
+	ErrInvalidSignature   = errors.New("invalid signature")
+	ErrURLExpired         = errors.New("URL expired")
+)
+
+// signURL adds expiry and HMAC-SHA256 signature of the method, the path, the version and the expiry to u
+func (b *Local) signURL(u *url.URL, method, path string, expires time.Time) {
+	q := u.Query()
+	q.Set(expiresQuery, strconv.FormatInt(expires.Unix(), 10))
+	q.Set(signatureQuery, b.signature(method, path, q.Get(versionQuery), q.Get(expiresQuery)))
+
+	u.RawQuery = q.Encode()
+}
+
+// Verify checks the signature and the expiry of the URL made by GetURL for the method and the path.
+// Use it to serve signed URLs by own handlers, Handler verifies them itself
+func (b *Local) Verify(method, path string, query url.Values) error {
+	if b.cfg.SigningKey == "" {
+		return ErrSigningKeyRequired
+	}
+
+	// HEAD is allowed by the URL for GET
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	expires, err := strconv.ParseInt(query.Get(expiresQuery), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: %w", expiresQuery, ErrInvalidSignature)
+	}
+
+	expected := b.signature(method, path, query.Get(versionQuery), query.Get(expiresQuery))
+	if !hmac.Equal([]byte(expected), []byte(query.Get(signatureQuery))) {
+		return ErrInvalidSignature
+	}
+
+	if time.Now().Unix() > expires {
+		return ErrURLExpired
+	}
+
+	return nil
+}
+
+func (b *Local) signature(method, path, versionID, expires string) string {
+	mac := hmac.New(sha256.New, []byte(b.cfg.SigningKey))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, path, versionID, expires)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseURLOptions returns the method and the expiry of the signed URL, zero expiry - the URL is not signed
+func parseURLOptions(cLink string, options ...interface{}) (method string, expires time.Time) {
+	method = http.MethodGet
+
+	for _, o := range options {
+		switch option := o.(type) {
+		case LocalOption:
+			if option == LinkForPutObject {
+				method = http.MethodPut
+			}
+		case core.ExpirationVerifier:
+			expires = option.GetAccessExpireTime(cLink)
+		}
+	}
+
+	if method == http.MethodPut && expires.IsZero() {
+		expires = time.Now().Add(putLinkLifeTime)
+	}
+
+	return method, expires
+}
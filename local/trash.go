@@ -0,0 +1,158 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// trashSeparator separates the deletion time in names of removed files: .trash/<path>~<unix nano>
+const trashSeparator = "~"
+
+// Restore moves the latest removed file from trash back to its path, older ones are kept until PurgeTrash
+func (b *Local) Restore(cLink string) (err error) {
+	path := b.cLinkToPath(cLink)
+	if path == "" {
+		return ErrFailedGetFilePath
+	}
+
+	path, internalPath, err := b.safeInternalPath(path)
+	if err != nil {
+		return err
+	}
+
+	trashPath, err := b.latestTrashPath(path)
+	if err != nil {
+		return err
+	}
+
+	if trashPath == "" {
+		return fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+
+	if _, err = os.Stat(internalPath); err == nil {
+		return fmt.Errorf("%s: %w", cLink, core.ErrObjectExists)
+	}
+
+	return b.move(trashPath, path)
+}
+
+// PurgeTrash removes files kept in trash longer than TrashRetention.
+// It is called by the janitor every JanitorInterval
+func (b *Local) PurgeTrash() (purged int, err error) {
+	root := b.pathToInternalPath(common.TrashDir)
+	deadline := time.Now().Add(-common.GetTrashRetention(b.cfg.TrashRetention))
+
+	err = filepath.Walk(root, func(internalPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !fi.Mode().IsRegular() || fi.ModTime().After(deadline) {
+			return nil
+		}
+
+		trashPath, err := b.relativePath(internalPath)
+		if err != nil {
+			return nil // nolint:nilerr
+		}
+
+		if err = os.Remove(internalPath); err != nil {
+			return fmt.Errorf("failed to remove file: %w", err)
+		}
+
+		purged++
+
+		return b.writeMeta(trashPath, metadata{})
+	})
+	if err != nil {
+		return purged, fmt.Errorf("failed to purge trash: %w", err)
+	}
+
+	return purged, nil
+}
+
+// trash moves file to trash, modification time of the file in trash is the deletion time.
+// The deletion time is also added to the name, the same path may be removed many times
+func (b *Local) trash(path string) error {
+	now := time.Now()
+	trashPath := common.TrashPath(path) + trashSeparator + strconv.FormatInt(now.UnixNano(), 10)
+
+	if err := b.move(path, trashPath); err != nil {
+		return err
+	}
+
+	if err := os.Chtimes(b.pathToInternalPath(trashPath), now, now); err != nil {
+		return fmt.Errorf("failed to set deletion time: %w", err)
+	}
+
+	// temporary file is not expired in trash
+	return b.writeExpiration(path, time.Time{})
+}
+
+// latestTrashPath returns the last removed file of path in trash, empty if there is none
+func (b *Local) latestTrashPath(path string) (trashPath string, err error) {
+	base := common.TrashPath(path)
+	internalPath := b.pathToInternalPath(base)
+	prefix := filepath.Base(internalPath) + trashSeparator
+
+	entries, err := os.ReadDir(filepath.Dir(internalPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var latest int64
+
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+
+		deleted, err := strconv.ParseInt(strings.TrimPrefix(e.Name(), prefix), 10, 64)
+		if err != nil || deleted <= latest {
+			continue
+		}
+
+		latest = deleted
+		trashPath = base + trashSeparator + strconv.FormatInt(deleted, 10)
+	}
+
+	return trashPath, nil
+}
+
+// move renames file with its metadata sidecar
+func (b *Local) move(from, to string) error {
+	fromPath, toPath := b.pathToInternalPath(from), b.pathToInternalPath(to)
+
+	if err := os.MkdirAll(filepath.Dir(toPath), mkdirPerm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(fromPath, toPath); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	m, err := b.readMeta(from)
+	if err != nil {
+		return err
+	}
+
+	if err = b.writeMeta(to, m); err != nil {
+		return err
+	}
+
+	return b.writeMeta(from, metadata{})
+}
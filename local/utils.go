@@ -9,9 +9,13 @@ import (
 	"strings"
 
 	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
 )
 
-const mkdirPerm = 0o770
+const (
+	mkdirPerm = 0o770
+	filePerm  = 0o660
+)
 
 var ErrFileNotRegular = errors.New("not a regular file")
 
@@ -31,8 +35,96 @@ func (b *Local) internalPathToPath(internalPath string) (path string) {
 	return common.InternalPathToPath(b.cfg.Root, internalPath)
 }
 
-func (b *Local) storeByPath(filePath string, path string) (cLink string, err error) {
-	return b.storeByInternalPath(filePath, b.pathToInternalPath(path))
+// relativePath converts path found on disk to path in the storage
+func (b *Local) relativePath(internalPath string) (path string, err error) {
+	path, err = filepath.Rel(filepath.Clean(endSlash(b.cfg.Root)), internalPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	return filepath.ToSlash(path), nil
+}
+
+func (b *Local) storeByPath(filePath string, path string, opts core.StoreOptions) (cLink string, err error) {
+	path, internalPath, err := b.safeInternalPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	if err = checkExpiration(opts.ExpiresAt); err != nil {
+		return "", err
+	}
+
+	if b.cfg.Versioning {
+		if err = b.keepVersion(path); err != nil {
+			return "", err
+		}
+	}
+
+	cLink, err = b.storeByInternalPath(filePath, internalPath)
+	if err != nil {
+		return "", err
+	}
+
+	// stale sidecar and expiration of the replaced object are removed
+	if err = b.writeMeta(path, newMetadata(opts)); err != nil {
+		return "", err
+	}
+
+	if err = b.writeExpiration(path, opts.ExpiresAt); err != nil {
+		return "", err
+	}
+
+	return cLink, nil
+}
+
+// safeInternalPath normalizes path by the path policy
+// and checks that the internal path does not escape Root
+func (b *Local) safeInternalPath(path string) (normalized, internalPath string, err error) {
+	normalized, err = b.policy.Normalize(path)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	if isReserved(normalized) {
+		return "", "", fmt.Errorf("%s: %w", path, common.ErrInvalidPath)
+	}
+
+	internalPath = b.pathToInternalPath(normalized)
+
+	root, err := filepath.Abs(endSlash(b.cfg.Root))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get root: %w", err)
+	}
+
+	abs, err := filepath.Abs(internalPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	if rel, err := filepath.Rel(root, abs); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", "", fmt.Errorf("%s: %w", path, common.ErrPathTraversal)
+	}
+
+	return normalized, internalPath, nil
+}
+
+// normalizePrefix normalizes not empty prefix keeping its trailing slash
+func (b *Local) normalizePrefix(prefix string) (string, error) {
+	if strings.Trim(prefix, "/") == "" {
+		return "", nil
+	}
+
+	normalized, err := b.policy.Normalize(prefix)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", prefix, err)
+	}
+
+	if strings.HasSuffix(prefix, "/") {
+		normalized += "/"
+	}
+
+	return normalized, nil
 }
 
 func (b *Local) storeByInternalPath(filePath, internalPath string) (cLink string, err error) {
@@ -47,6 +139,11 @@ func (b *Local) storeByInternalPath(filePath, internalPath string) (cLink string
 	return cLink, nil
 }
 
+// fileETag is a strong validator built from modification time and size, so Range requests can use If-Range
+func fileETag(fi os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size())
+}
+
 func checkStorageKey(cLink string, storageKey string) (ok bool) {
 	return common.CheckStorageKey(cLink, storageKey)
 }
@@ -0,0 +1,187 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rosberry/storage/core"
+)
+
+const (
+	// versionsDir keeps previous versions of files in versioning mode: Root/.versions/<path>.<version ID>
+	versionsDir = ".versions"
+
+	// versionQuery is the URL query parameter with version ID, the same as in s3
+	versionQuery = "versionId"
+)
+
+// version ID is modification time of the file in nanoseconds, 16 hex digits
+var versionIDRe = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// ListVersions lists the file and its previous versions, the latest first
+func (b *Local) ListVersions(cLink string) (versions []core.VersionInfo, err error) {
+	p := b.cLinkToPath(cLink)
+	if p == "" {
+		return nil, ErrFailedGetFilePath
+	}
+
+	p, _, err = b.safeInternalPath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := b.Stat(b.pathToCLink(p))
+	if err == nil {
+		versions = append(versions, core.VersionInfo{
+			ObjectInfo: info,
+			VersionID:  versionID(info.LastModified),
+			IsLatest:   true,
+		})
+	} else if !errors.Is(err, core.ErrObjectNotFound) {
+		return nil, err
+	}
+
+	dir := b.pathToInternalPath(versionsDir + "/" + path.Dir(p))
+	prefix := path.Base(p) + "."
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read versions: %w", err)
+	}
+
+	for _, e := range entries {
+		id := strings.TrimPrefix(e.Name(), prefix)
+		if !strings.HasPrefix(e.Name(), prefix) || !versionIDRe.MatchString(id) || !e.Type().IsRegular() {
+			continue
+		}
+
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		m, err := b.readMeta(versionPath(p, id))
+		if err != nil {
+			return nil, err
+		}
+
+		v := core.VersionInfo{
+			ObjectInfo: core.ObjectInfo{
+				CLink:        b.pathToCLink(p),
+				Path:         p,
+				Size:         fi.Size(),
+				ETag:         fileETag(fi),
+				LastModified: fi.ModTime(),
+			},
+			VersionID: id,
+		}
+
+		b.applyMeta(&v.ObjectInfo, m)
+
+		versions = append(versions, v)
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+
+	return versions, nil
+}
+
+// RestoreVersion copies the version over the file, the current file is kept as a version
+func (b *Local) RestoreVersion(cLink, versionID string) (err error) {
+	p := b.cLinkToPath(cLink)
+	if p == "" {
+		return ErrFailedGetFilePath
+	}
+
+	p, internalPath, err := b.safeInternalPath(p)
+	if err != nil {
+		return err
+	}
+
+	if fi, err := os.Stat(internalPath); err == nil && fileVersionID(fi) == versionID {
+		return nil
+	}
+
+	src, err := b.versionInternalPath(p, versionID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cLink, err)
+	}
+
+	m, err := b.readMeta(versionPath(p, versionID))
+	if err != nil {
+		return err
+	}
+
+	if err = b.keepVersion(p); err != nil {
+		return err
+	}
+
+	if err = copyFile(src, internalPath, b.cfg.BufferSize); err != nil {
+		return err
+	}
+
+	if err = b.writeExpiration(p, time.Time{}); err != nil {
+		return err
+	}
+
+	return b.writeMeta(p, m)
+}
+
+// keepVersion moves the current file to versions, missing file is skipped
+func (b *Local) keepVersion(p string) error {
+	fi, err := os.Stat(b.pathToInternalPath(p))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat: %w", err)
+	}
+
+	return b.move(p, versionPath(p, fileVersionID(fi)))
+}
+
+// versionInternalPath returns internal path of the version, current file for its own version ID
+func (b *Local) versionInternalPath(p, id string) (string, error) {
+	if !versionIDRe.MatchString(id) {
+		return "", fmt.Errorf("version %q: %w", id, core.ErrObjectNotFound)
+	}
+
+	internalPath := b.pathToInternalPath(p)
+	if fi, err := os.Stat(internalPath); err == nil && fileVersionID(fi) == id {
+		return internalPath, nil
+	}
+
+	internalPath = b.pathToInternalPath(versionPath(p, id))
+	if _, err := os.Stat(internalPath); err != nil {
+		return "", fmt.Errorf("version %s: %w", id, core.ErrObjectNotFound)
+	}
+
+	return internalPath, nil
+}
+
+func versionPath(p, id string) string {
+	return versionsDir + "/" + p + "." + id
+}
+
+func fileVersionID(fi os.FileInfo) string {
+	return versionID(fi.ModTime())
+}
+
+func versionID(t time.Time) string {
+	id := strconv.FormatInt(t.UnixNano(), 16)
+
+	return strings.Repeat("0", 16-len(id)) + id
+}
@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// checkpoint - paths of copied objects, one per line. New paths are appended
+// right after the copy, so the interrupted migration loses nothing
+type checkpoint struct {
+	mu   sync.Mutex
+	f    *os.File
+	done map[string]bool
+}
+
+// openCheckpoint reads paths of the previous runs, empty path - no checkpoint.
+// In dry run the file is only read
+func openCheckpoint(path string, readOnly bool) (*checkpoint, error) {
+	cp := &checkpoint{done: make(map[string]bool)}
+	if path == "" {
+		return cp, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+
+	if err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				cp.done[line] = true
+			}
+		}
+
+		f.Close()
+
+		if err = scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+	}
+
+	if readOnly {
+		return cp, nil
+	}
+
+	cp.f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+
+	return cp, nil
+}
+
+func (cp *checkpoint) Done(path string) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	return cp.done[path]
+}
+
+func (cp *checkpoint) Add(path string) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.done[path] = true
+
+	if cp.f == nil {
+		return nil
+	}
+
+	if _, err := cp.f.WriteString(path + "\n"); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (cp *checkpoint) Close() error {
+	if cp.f == nil {
+		return nil
+	}
+
+	return cp.f.Close() // nolint:wrapcheck
+}
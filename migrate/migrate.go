@@ -0,0 +1,223 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	Config struct {
+		Source core.Storage // should implement core.Lister and core.Opener
+		Target core.Storage
+		Prefix string // only objects which path starts with Prefix are copied
+
+		Workers int // parallel copies, default DefaultWorkers
+
+		// file with paths of copied objects, they are skipped by the next run.
+		// Empty - the migration is not resumable
+		Checkpoint string
+
+		// only count objects to copy, nothing is written
+		DryRun bool
+
+		TempDir string // directory for downloaded objects, default os.TempDir()
+	}
+
+	Report struct {
+		Listed  int   // objects found in Source
+		Skipped int   // objects copied by previous runs
+		Copied  int   // copied objects, in DryRun - objects to copy
+		Bytes   int64 // size of Copied objects
+		Failed  []Failure
+	}
+
+	// Failure - object failed to copy, it is copied again by the next run
+	Failure struct {
+		Path string
+		Err  error
+	}
+)
+
+const DefaultWorkers = 4
+
+// Copy copies all objects of Source to Target by the same paths with their headers, user metadata,
+// visibility and expiration. Failed objects do not stop the migration, they are reported in Report.Failed,
+// as well as objects which visibility or expiration Target can not keep
+func Copy(cfg *Config) (report Report, err error) {
+	cp, err := openCheckpoint(cfg.Checkpoint, cfg.DryRun)
+	if err != nil {
+		return report, err
+	}
+	defer cp.Close()
+
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		queue = make(chan core.ObjectInfo)
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for info := range queue {
+				e := copyObject(cfg, info)
+				if e == nil {
+					e = cp.Add(info.Path)
+				}
+
+				mu.Lock()
+				if e != nil {
+					report.Failed = append(report.Failed, Failure{Path: info.Path, Err: e})
+				} else {
+					report.Copied++
+					report.Bytes += info.Size
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err = core.List(cfg.Source, cfg.Prefix, func(info core.ObjectInfo) error {
+		mu.Lock()
+		report.Listed++
+
+		if cp.Done(info.Path) {
+			report.Skipped++
+			mu.Unlock()
+			return nil
+		}
+
+		if cfg.DryRun {
+			report.Copied++
+			report.Bytes += info.Size
+			mu.Unlock()
+			return nil
+		}
+		mu.Unlock()
+
+		queue <- info
+
+		return nil
+	})
+
+	close(queue)
+	wg.Wait()
+
+	if err != nil {
+		return report, fmt.Errorf("failed to list source: %w", err)
+	}
+
+	return report, nil
+}
+
+// copyObject downloads the object to the temp file and stores it to Target
+func copyObject(cfg *Config, info core.ObjectInfo) error {
+	cLink := cfg.Source.GetCLink(info.Path)
+
+	// List does not return all headers, Stat does if the storage supports it
+	stat, err := core.Stat(cfg.Source, cLink)
+	if err == nil {
+		info = stat
+	} else if !errors.Is(err, core.ErrNotSupported) {
+		return fmt.Errorf("failed to stat: %w", err)
+	}
+
+	// visibility and expiration are not headers, the target has to keep them itself
+	options := core.MetadataOptions(info)
+
+	v, err := core.GetVisibility(cfg.Source, cLink)
+	if err != nil && !errors.Is(err, core.ErrNotSupported) {
+		return fmt.Errorf("failed to get visibility: %w", err)
+	}
+
+	if v != "" {
+		if !canSetVisibility(cfg.Target) {
+			return fmt.Errorf("target can not keep %s visibility: %w", v, core.ErrNotSupported)
+		}
+
+		options = append(options, v)
+	}
+
+	if !info.ExpiresAt.IsZero() {
+		options = append(options, core.ExpiresAt(info.ExpiresAt))
+	}
+
+	// the bytes are copied as stored, Content-Encoding of the metadata describes them
+	rc, err := core.OpenRange(cfg.Source, cLink, 0, -1)
+	if err != nil {
+		return fmt.Errorf("failed to open: %w", err)
+	}
+	defer rc.Close()
+
+	f, err := os.CreateTemp(cfg.TempDir, "migrate-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, rc)
+	f.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to read: %w", err)
+	}
+
+	target, err := cfg.Target.Store(f.Name(), info.Path, options...)
+	if err != nil {
+		return fmt.Errorf("failed to store: %w", err)
+	}
+
+	if !info.ExpiresAt.IsZero() {
+		return checkExpiration(cfg.Target, target)
+	}
+
+	return nil
+}
+
+// canSetVisibility reports whether s or a storage wrapped by it implements core.VisibilitySetter
+func canSetVisibility(s core.Storage) bool {
+	for s != nil {
+		if _, ok := s.(core.VisibilitySetter); ok {
+			return true
+		}
+
+		u, ok := s.(core.Unwrapper)
+		if !ok {
+			return false
+		}
+		s = u.Unwrap()
+	}
+
+	return false
+}
+
+// checkExpiration removes the copy of the temporary object if the target stored it without expiration
+func checkExpiration(s core.Storage, cLink string) error {
+	info, err := core.Stat(s, cLink)
+	if err == nil && !info.ExpiresAt.IsZero() {
+		return nil
+	}
+
+	if err == nil || errors.Is(err, core.ErrNotSupported) {
+		err = fmt.Errorf("target can not keep expiration: %w", core.ErrNotSupported)
+	}
+
+	if e := s.Remove(cLink); e != nil {
+		return fmt.Errorf("%v, failed to remove the copy: %w", err, e)
+	}
+
+	return err
+}
@@ -0,0 +1,166 @@
+package migrate
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rosberry/storage/compress"
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+func newTestStorage(t *testing.T, key string) *local.Local {
+	return local.New(&local.Config{
+		StorageKey: key,
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+	})
+}
+
+func TestCopy(t *testing.T) {
+	src := newTestStorage(t, "old")
+	dst := newTestStorage(t, "new")
+
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, []byte("migrate"), 0o644)
+
+	src.Store(tmp, "a/1.txt", core.ContentType("text/csv"), core.UserMetadata{"owner": "42"})
+	src.Store(tmp, "a/2.txt")
+	src.Store(tmp, "b/3.txt")
+
+	cfg := &Config{
+		Source:     src,
+		Target:     dst,
+		Prefix:     "a/",
+		Checkpoint: filepath.Join(t.TempDir(), "checkpoint"),
+		DryRun:     true,
+	}
+
+	report, err := Copy(cfg)
+	if err != nil || report.Listed != 2 || report.Copied != 2 || report.Bytes != 14 {
+		t.Errorf("unexpected report: %+v, err %v", report, err)
+	}
+
+	if _, err = dst.Stat("new:a/1.txt"); err == nil {
+		t.Errorf("object is copied in dry run")
+	}
+
+	cfg.DryRun = false
+
+	if report, err = Copy(cfg); err != nil || report.Copied != 2 || len(report.Failed) != 0 {
+		t.Errorf("unexpected report: %+v, err %v", report, err)
+	}
+
+	info, err := dst.Stat("new:a/1.txt")
+	if err != nil || info.ContentType != "text/csv" || info.Metadata["owner"] != "42" {
+		t.Errorf("unexpected info: %+v, err %v", info, err)
+	}
+
+	// the next run continues from the checkpoint
+	src.Store(tmp, "a/4.txt")
+	cfg.Prefix = ""
+
+	if report, err = Copy(cfg); err != nil || report.Listed != 4 || report.Skipped != 2 || report.Copied != 2 {
+		t.Errorf("unexpected report: %+v, err %v", report, err)
+	}
+}
+
+func TestCopyCompressed(t *testing.T) {
+	src := compress.New(&compress.Config{
+		StorageKey:   "old",
+		StorageCtl:   newTestStorage(t, "old"),
+		ContentTypes: []string{"text/"},
+	})
+	dst := newTestStorage(t, "new")
+
+	content := []byte(strings.Repeat("compressed content\n", 100))
+
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, content, 0o644)
+	src.Store(tmp, "a.txt")
+
+	if report, err := Copy(&Config{Source: src, Target: dst}); err != nil || report.Copied != 1 {
+		t.Fatalf("unexpected report: %+v, err %v", report, err)
+	}
+
+	// the target keeps the compressed bytes with their Content-Encoding
+	info, _ := dst.Stat("new:a.txt")
+	if info.ContentEncoding != compress.AlgorithmGzip || info.Size >= int64(len(content)) {
+		t.Errorf("got %+v, want compressed object", info)
+	}
+
+	rc, err := compress.New(&compress.Config{StorageKey: "new", StorageCtl: dst}).Open("new:a.txt")
+	if err != nil {
+		t.Fatalf("Open err: %q", err)
+	}
+	defer rc.Close()
+
+	if copied, _ := ioutil.ReadAll(rc); string(copied) != string(content) {
+		t.Errorf("copied object is corrupted")
+	}
+}
+
+// publicStorage - source which objects are public
+type publicStorage struct {
+	*local.Local
+}
+
+func (publicStorage) GetVisibility(cLink string) (core.Visibility, error) {
+	return core.VisibilityPublic, nil
+}
+
+func TestCopyVisibilityExpiration(t *testing.T) {
+	src := newTestStorage(t, "old")
+	dst := newTestStorage(t, "new")
+
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, []byte("migrate"), 0o644)
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	src.Store(tmp, "tmp.txt", core.ExpiresAt(expiresAt))
+
+	if report, err := Copy(&Config{Source: src, Target: dst}); err != nil || report.Copied != 1 {
+		t.Fatalf("unexpected report: %+v, err %v", report, err)
+	}
+
+	if info, err := dst.Stat("new:tmp.txt"); err != nil || !info.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("got %+v, err %v, want expiration %v", info, err, expiresAt)
+	}
+
+	// local can not keep public access, the object is reported instead of being copied
+	report, err := Copy(&Config{Source: publicStorage{src}, Target: newTestStorage(t, "new")})
+	if err != nil || report.Copied != 0 || len(report.Failed) != 1 || !errors.Is(report.Failed[0].Err, core.ErrNotSupported) {
+		t.Errorf("unexpected report: %+v, err %v", report, err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	flagtests := []struct {
+		name     string
+		in       string
+		out      string
+		replaced int
+	}{
+		{"json", `{"avatar":"yos:users/1.jpg","list":["yos:a.png","s3:b.png"]}`, `{"avatar":"s3:users/1.jpg","list":["s3:a.png","s3:b.png"]}`, 2},
+		{"csv", "1,yos:a.jpg,yos:/b/\n2,,myyos:c.jpg\n", "1,s3:a.jpg,s3:b\n2,,myyos:c.jpg\n", 2},
+		{"sql", "INSERT INTO files VALUES (1,'yos:docs/a b.pdf'),(2,'yos:c.pdf');", "INSERT INTO files VALUES (1,'s3:docs/a b.pdf'),(2,'s3:c.pdf');", 2},
+		{"start", "yos:a.jpg", "s3:a.jpg", 1},
+		{"url", `{"url":"https://host/yos:a.jpg","src":"yos:a.jpg"}`, `{"url":"https://host/yos:a.jpg","src":"s3:a.jpg"}`, 1},
+		{"key in path", "1,files/yos:a.jpg,x-yos:b.jpg\n", "1,files/yos:a.jpg,x-yos:b.jpg\n", 0},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+
+			replaced, err := NewRewriter("yos", "s3").Rewrite(strings.NewReader(tt.in), &out)
+			if err != nil || out.String() != tt.out || replaced != tt.replaced {
+				t.Errorf("got %q, %d, %v, want %q, %d", out.String(), replaced, err, tt.out, tt.replaced)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/rosberry/storage/common"
+)
+
+// Rewriter replaces cLinks of one storage key with another one in text streams:
+// JSON, CSV, SQL dumps. The cLink starts and ends at a space, a quote, a comma, a semicolon or a bracket
+type Rewriter struct {
+	newKey string
+	re     *regexp.Regexp
+}
+
+func NewRewriter(oldKey, newKey string) *Rewriter {
+	// the cLink starts and ends at the same delimiters, so "yos:" in "myyos:a.jpg"
+	// or in "https://host/yos:a.jpg" is not a cLink
+	delimiters := `\s"'` + "`" + `,;()\[\]{}<>\\`
+
+	return &Rewriter{
+		newKey: newKey,
+		re:     regexp.MustCompile(`(^|[` + delimiters + `])` + regexp.QuoteMeta(oldKey) + `:([^` + delimiters + `]+)`),
+	}
+}
+
+// Rewrite copies r to w replacing oldKey:path with newKey:path by common.PathToCLink.
+// Use ioutil.Discard as w to count cLinks only
+func (rw *Rewriter) Rewrite(r io.Reader, w io.Writer) (replaced int, err error) {
+	br := bufio.NewReader(r)
+
+	for {
+		line, readErr := br.ReadString('\n')
+
+		if line != "" {
+			line = rw.re.ReplaceAllStringFunc(line, func(m string) string {
+				replaced++
+
+				sub := rw.re.FindStringSubmatch(m)
+
+				return sub[1] + common.PathToCLink(rw.newKey, sub[2])
+			})
+
+			if _, err = io.WriteString(w, line); err != nil {
+				return replaced, fmt.Errorf("failed to write: %w", err)
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			return replaced, nil
+		}
+
+		if readErr != nil {
+			return replaced, fmt.Errorf("failed to read: %w", readErr)
+		}
+	}
+}
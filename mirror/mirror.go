@@ -0,0 +1,359 @@
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	Config struct {
+		StorageKey string
+		Primary    core.Storage
+		Replicas   []core.Storage
+		WriteMode  WriteMode
+		TempDir    string
+	}
+
+	MirrorStorage struct {
+		cfg     Config
+		targets []core.Storage
+
+		mu    sync.Mutex
+		queue []RepairItem
+	}
+
+	WriteMode string
+
+	// RepairItem - object that was not written to one of the targets
+	RepairItem struct {
+		Path     string
+		Target   int // 0 - primary, 1.. - replicas in config order
+		Options  []interface{}
+		Err      error
+		FailedAt time.Time
+		Attempts int
+	}
+)
+
+const (
+	// WriteAll - Store fails if any of the targets failed, written copies of new objects are removed,
+	// overwritten objects are queued for repair
+	WriteAll WriteMode = "all"
+	// WriteQuorum - Store succeeds if the majority of the targets succeeded, failures are handled as in WriteAll
+	WriteQuorum WriteMode = "quorum"
+)
+
+var (
+	ErrWriteFailed      = errors.New("not enough storages succeeded")
+	ErrNoHealthyStorage = errors.New("no healthy storage")
+)
+
+func New(cfg *Config) *MirrorStorage {
+	m := &MirrorStorage{
+		cfg: *cfg,
+	}
+
+	if m.cfg.WriteMode == "" {
+		m.cfg.WriteMode = WriteAll
+	}
+
+	m.targets = append([]core.Storage{m.cfg.Primary}, m.cfg.Replicas...)
+
+	return m
+}
+
+func (m *MirrorStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	errs := make([]error, len(m.targets))
+	existed := make([]bool, len(m.targets))
+
+	var wg sync.WaitGroup
+
+	for i, t := range m.targets {
+		wg.Add(1)
+
+		go func(i int, t core.Storage) {
+			defer wg.Done()
+
+			existed[i] = exists(t, t.GetCLink(path))
+			_, errs[i] = t.Store(filePath, path, options...)
+		}(i, t)
+	}
+
+	wg.Wait()
+
+	var (
+		succeeded int
+		kept      int
+		failed    []string
+	)
+
+	for i, e := range errs {
+		if e == nil {
+			succeeded++
+			continue
+		}
+
+		failed = append(failed, fmt.Sprintf("storage %d: %v", i, e))
+	}
+
+	if succeeded < m.required() {
+		// new copies are removed, overwritten ones are kept as the previous copy is gone anyway
+		// and the failed storages are repaired from them
+		for i, e := range errs {
+			if e != nil {
+				continue
+			}
+
+			if existed[i] {
+				kept++
+				continue
+			}
+
+			if e = m.targets[i].Remove(m.targets[i].GetCLink(path)); e != nil {
+				failed = append(failed, fmt.Sprintf("storage %d: rollback: %v", i, e))
+				kept++
+			}
+		}
+	}
+
+	if succeeded >= m.required() || kept > 0 {
+		for i, e := range errs {
+			if e != nil {
+				m.enqueue(RepairItem{
+					Path:     path,
+					Target:   i,
+					Options:  options,
+					Err:      e,
+					FailedAt: time.Now(),
+				})
+			}
+		}
+	}
+
+	if succeeded < m.required() {
+		return "", fmt.Errorf("%w: %s", ErrWriteFailed, strings.Join(failed, "; "))
+	}
+
+	return m.GetCLink(path), nil
+}
+
+func (m *MirrorStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
+	_, err = m.Store(filePath, common.CLinkToPath(m.cfg.StorageKey, cLink), options...)
+	return err
+}
+
+// GetURL returns URL from the primary storage, or from the first replica
+// when the primary reports the object missing or fails to stat it
+func (m *MirrorStorage) GetURL(cLink string, options ...interface{}) string {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+	if path == "" {
+		return ""
+	}
+
+	for _, t := range m.targets {
+		tCLink := t.GetCLink(path)
+		if !healthy(t, tCLink) {
+			continue
+		}
+
+		if URL := t.GetURL(tCLink, options...); URL != "" {
+			return URL
+		}
+	}
+
+	return ""
+}
+
+func (m *MirrorStorage) Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+
+	err = ErrNoHealthyStorage
+
+	for _, t := range m.targets {
+		tCLink := t.GetCLink(path)
+		if !healthy(t, tCLink) {
+			continue
+		}
+
+		rc, err = core.Open(t, tCLink, options...)
+		if err == nil {
+			return rc, nil
+		}
+	}
+
+	return nil, err
+}
+
+// OpenRange reads the object as stored from the first healthy storage, see core.OpenRange
+func (m *MirrorStorage) OpenRange(cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error) {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+
+	err = ErrNoHealthyStorage
+
+	for _, t := range m.targets {
+		tCLink := t.GetCLink(path)
+		if !healthy(t, tCLink) {
+			continue
+		}
+
+		rc, err = core.OpenRange(t, tCLink, offset, length, options...)
+		if err == nil {
+			return rc, nil
+		}
+	}
+
+	return nil, err
+}
+
+func (m *MirrorStorage) Stat(cLink string) (info core.ObjectInfo, err error) {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+
+	for _, t := range m.targets {
+		info, err = core.Stat(t, t.GetCLink(path))
+		if err == nil {
+			info.CLink = m.GetCLink(path)
+			return info, nil
+		}
+	}
+
+	return info, err
+}
+
+// Remove removes object from all storages, replicas are cleaned even if the primary failed
+func (m *MirrorStorage) Remove(cLink string) (err error) {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+
+	var failed []string
+
+	for i, t := range m.targets {
+		if e := t.Remove(t.GetCLink(path)); e != nil {
+			failed = append(failed, fmt.Sprintf("storage %d: %v", i, e))
+		}
+	}
+
+	m.dequeue(path)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove %s: %s", cLink, strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// UpdateMetadata updates metadata in all storages, see core.UpdateMetadata
+func (m *MirrorStorage) UpdateMetadata(cLink string, options ...interface{}) (err error) {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+
+	var failed []string
+
+	for i, t := range m.targets {
+		if e := core.UpdateMetadata(t, t.GetCLink(path), options...); e != nil {
+			failed = append(failed, fmt.Sprintf("storage %d: %v", i, e))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to update metadata %s: %s", cLink, strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// GetVisibility returns visibility from the first storage which has the object, see core.GetVisibility
+func (m *MirrorStorage) GetVisibility(cLink string) (v core.Visibility, err error) {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+
+	for _, t := range m.targets {
+		v, err = core.GetVisibility(t, t.GetCLink(path))
+		if err == nil {
+			return v, nil
+		}
+	}
+
+	return "", err
+}
+
+// SetVisibility changes visibility in all storages, see core.SetVisibility
+func (m *MirrorStorage) SetVisibility(cLink string, v core.Visibility) (err error) {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+
+	var failed []string
+
+	for i, t := range m.targets {
+		if e := core.SetVisibility(t, t.GetCLink(path), v); e != nil {
+			failed = append(failed, fmt.Sprintf("storage %d: %v", i, e))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to set visibility %s: %s", cLink, strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// Restore restores object in all storages, storages without the object in trash are skipped
+func (m *MirrorStorage) Restore(cLink string) (err error) {
+	path := common.CLinkToPath(m.cfg.StorageKey, cLink)
+
+	var (
+		failed   []string
+		restored int
+	)
+
+	for i, t := range m.targets {
+		e := core.Restore(t, t.GetCLink(path))
+		if e == nil {
+			restored++
+			continue
+		}
+
+		if !errors.Is(e, core.ErrObjectNotFound) {
+			failed = append(failed, fmt.Sprintf("storage %d: %v", i, e))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restore %s: %s", cLink, strings.Join(failed, "; "))
+	}
+
+	if restored == 0 {
+		return fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+
+	return nil
+}
+
+func (m *MirrorStorage) GetCLink(path string) (cLink string) {
+	return common.PathToCLink(m.cfg.StorageKey, path)
+}
+
+func (m *MirrorStorage) required() int {
+	if m.cfg.WriteMode == WriteQuorum {
+		return len(m.targets)/2 + 1
+	}
+
+	return len(m.targets)
+}
+
+// exists reports if the object is in the storage, storages without Stat are considered to have it
+func exists(s core.Storage, cLink string) bool {
+	_, err := core.Stat(s, cLink)
+
+	return !errors.Is(err, core.ErrObjectNotFound)
+}
+
+// healthy reports false only if the storage could check the object and it failed,
+// storages without Stat are considered healthy
+func healthy(s core.Storage, cLink string) bool {
+	_, err := core.Stat(s, cLink)
+
+	return err == nil || errors.Is(err, core.ErrNotSupported)
+}
@@ -0,0 +1,193 @@
+package mirror
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+var (
+	testStorageKey = "mirror"
+	errTest        = errors.New("test error")
+)
+
+// brokenStorage fails all writes until fixed
+type brokenStorage struct {
+	*local.Local
+	broken bool
+}
+
+func (b *brokenStorage) Store(filePath, path string, options ...interface{}) (string, error) {
+	if b.broken {
+		return "", errTest
+	}
+	return b.Local.Store(filePath, path, options...)
+}
+
+func newLocal(t *testing.T, key string) *local.Local {
+	return local.New(&local.Config{
+		StorageKey: key,
+		Endpoint:   "http://" + key + ".com/",
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+	})
+}
+
+func newTestFile(t *testing.T) string {
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, []byte("hello\ngo\n"), 0o644)
+
+	return tmp
+}
+
+func TestStoreAll(t *testing.T) {
+	primary := newLocal(t, "primary")
+	replica := &brokenStorage{Local: newLocal(t, "replica"), broken: true}
+
+	m := New(&Config{
+		StorageKey: testStorageKey,
+		Primary:    primary,
+		Replicas:   []core.Storage{replica},
+		WriteMode:  WriteAll,
+	})
+
+	_, err := m.Store(newTestFile(t), "folder/file.txt")
+	if !errors.Is(err, ErrWriteFailed) {
+		t.Errorf("got %v, want %v", err, ErrWriteFailed)
+	}
+
+	if len(m.RepairQueue()) != 0 {
+		t.Errorf("failed write should not be queued for repair")
+	}
+
+	if _, err = primary.Stat(primary.GetCLink("folder/file.txt")); !errors.Is(err, core.ErrObjectNotFound) {
+		t.Errorf("written copy is not rolled back: %v", err)
+	}
+}
+
+func TestStoreAllOverwrite(t *testing.T) {
+	primary := newLocal(t, "primary")
+	replica := &brokenStorage{Local: newLocal(t, "replica")}
+
+	m := New(&Config{
+		StorageKey: testStorageKey,
+		Primary:    primary,
+		Replicas:   []core.Storage{replica},
+		WriteMode:  WriteAll,
+	})
+
+	if _, err := m.Store(newTestFile(t), "folder/file.txt"); err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	replica.broken = true
+
+	if _, err := m.Store(newTestFile(t), "folder/file.txt"); !errors.Is(err, ErrWriteFailed) {
+		t.Errorf("got %v, want %v", err, ErrWriteFailed)
+	}
+
+	// the overwritten object is not removed, the failed storage is repaired instead
+	if _, err := primary.Stat(primary.GetCLink("folder/file.txt")); err != nil {
+		t.Errorf("overwritten copy is removed: %v", err)
+	}
+
+	if queue := m.RepairQueue(); len(queue) != 1 || queue[0].Target != 1 {
+		t.Errorf("unexpected repair queue: %+v", queue)
+	}
+}
+
+func TestStoreQuorumAndRepair(t *testing.T) {
+	primary := newLocal(t, "primary")
+	replica1 := newLocal(t, "replica1")
+	replica2 := &brokenStorage{Local: newLocal(t, "replica2"), broken: true}
+
+	m := New(&Config{
+		StorageKey: testStorageKey,
+		Primary:    primary,
+		Replicas:   []core.Storage{replica1, replica2},
+		WriteMode:  WriteQuorum,
+	})
+
+	cLink, err := m.Store(newTestFile(t), "folder/file.txt")
+	if err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	if cLink != testStorageKey+":folder/file.txt" {
+		t.Errorf("got %q, want %q", cLink, testStorageKey+":folder/file.txt")
+	}
+
+	queue := m.RepairQueue()
+	if len(queue) != 1 || queue[0].Target != 2 || queue[0].Path != "folder/file.txt" {
+		t.Fatalf("unexpected repair queue: %+v", queue)
+	}
+
+	if _, err = m.Repair(); err == nil {
+		t.Errorf("repair of broken storage should fail")
+	}
+
+	if queue = m.RepairQueue(); len(queue) != 1 || queue[0].Attempts != 1 {
+		t.Fatalf("unexpected repair queue: %+v", queue)
+	}
+
+	replica2.broken = false
+
+	repaired, err := m.Repair()
+	if err != nil || repaired != 1 {
+		t.Fatalf("Repair: %d, %v", repaired, err)
+	}
+
+	if _, err = replica2.Stat(replica2.GetCLink("folder/file.txt")); err != nil {
+		t.Errorf("object was not repaired: %v", err)
+	}
+
+	if len(m.RepairQueue()) != 0 {
+		t.Errorf("repair queue is not empty")
+	}
+}
+
+func TestGetURLFallback(t *testing.T) {
+	primary := newLocal(t, "primary")
+	replica := newLocal(t, "replica")
+
+	m := New(&Config{
+		StorageKey: testStorageKey,
+		Primary:    primary,
+		Replicas:   []core.Storage{replica},
+	})
+
+	cLink, err := m.Store(newTestFile(t), "file.txt")
+	if err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	if URL := m.GetURL(cLink); URL != "http://primary.com/file.txt" {
+		t.Errorf("got %q, want primary URL", URL)
+	}
+
+	if err = primary.Remove(primary.GetCLink("file.txt")); err != nil {
+		t.Fatalf("Remove err: %q", err)
+	}
+
+	if URL := m.GetURL(cLink); URL != "http://replica.com/file.txt" {
+		t.Errorf("got %q, want replica URL", URL)
+	}
+
+	rc, err := m.Open(cLink)
+	if err != nil {
+		t.Fatalf("Open err: %q", err)
+	}
+	rc.Close()
+
+	if err = m.Remove(cLink); err == nil {
+		t.Errorf("Remove should report missing object in primary")
+	}
+
+	if _, err = replica.Stat(replica.GetCLink("file.txt")); !errors.Is(err, core.ErrObjectNotFound) {
+		t.Errorf("object was not removed from replica")
+	}
+}
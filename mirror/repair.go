@@ -0,0 +1,131 @@
+package mirror
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rosberry/storage/core"
+)
+
+// RepairQueue returns a copy of objects waiting for replication
+func (m *MirrorStorage) RepairQueue() []RepairItem {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]RepairItem(nil), m.queue...)
+}
+
+// Repair copies queued objects from a healthy storage to the storages that missed them.
+// Items that failed again stay in the queue with increased Attempts
+func (m *MirrorStorage) Repair() (repaired int, err error) {
+	m.mu.Lock()
+	items := m.queue
+	m.queue = nil
+	m.mu.Unlock()
+
+	for _, item := range items {
+		e := m.repair(item)
+		if e == nil {
+			repaired++
+			continue
+		}
+
+		item.Err = e
+		item.Attempts++
+		item.FailedAt = time.Now()
+		m.enqueue(item)
+
+		err = e
+	}
+
+	return repaired, err
+}
+
+func (m *MirrorStorage) repair(item RepairItem) error {
+	target := m.targets[item.Target]
+
+	tmp, err := m.fetch(item)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if _, err = target.Store(tmp, item.Path, item.Options...); err != nil {
+		return fmt.Errorf("failed to store %s: %w", item.Path, err)
+	}
+
+	return nil
+}
+
+// fetch downloads the object from any storage except the failed one to the temp file
+func (m *MirrorStorage) fetch(item RepairItem) (tmpPath string, err error) {
+	err = ErrNoHealthyStorage
+
+	for i, t := range m.targets {
+		if i == item.Target {
+			continue
+		}
+
+		var rc io.ReadCloser
+
+		rc, err = core.Open(t, t.GetCLink(item.Path))
+		if err != nil {
+			continue
+		}
+
+		tmpPath, err = saveTemp(m.cfg.TempDir, rc)
+		rc.Close()
+
+		if err == nil {
+			return tmpPath, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to read %s: %w", item.Path, err)
+}
+
+func (m *MirrorStorage) enqueue(item RepairItem) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, queued := range m.queue {
+		if queued.Path == item.Path && queued.Target == item.Target {
+			m.queue[i] = item
+			return
+		}
+	}
+
+	m.queue = append(m.queue, item)
+}
+
+func (m *MirrorStorage) dequeue(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queue := m.queue[:0]
+
+	for _, item := range m.queue {
+		if item.Path != path {
+			queue = append(queue, item)
+		}
+	}
+
+	m.queue = queue
+}
+
+func saveTemp(dir string, r io.Reader) (string, error) {
+	f, err := os.CreateTemp(dir, "mirror-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err = io.Copy(f, r); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,141 @@
+package pathgen
+
+import (
+	"crypto/sha1" // nolint:gosec
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Template - path generator by template.
+// Placeholders:
+//
+//	{uuid} - random UUID
+//	{ulid} - ULID, sortable by creation time
+//	{yyyy}, {mm}, {dd}, {hh} - current UTC date and hour
+//	{shard} - two directory levels from the hash of {uuid}, e.g. "3f/a9"
+//	{name} - file name without extension
+//	{ext} - lower case file extension with dot, e.g. ".jpg"
+//	{anything} - value of the variable passed by core.PathVars
+type Template struct {
+	Template string
+}
+
+const (
+	GeneratorUUID     = "uuid"
+	GeneratorULID     = "ulid"
+	GeneratorDate     = "date"
+	GeneratorHash     = "hash"
+	GeneratorTemplate = "template"
+)
+
+var (
+	ErrUnknownGenerator = errors.New("unknown path generator")
+	ErrMissingVar       = errors.New("missing path variable")
+
+	placeholder = regexp.MustCompile(`\{[a-zA-Z0-9_-]+\}`)
+
+	// now is replaced in tests
+	now = time.Now
+)
+
+// UUID - "{uuid}{ext}"
+func UUID() *Template {
+	return &Template{Template: "{uuid}{ext}"}
+}
+
+// ULID - "{ulid}{ext}"
+func ULID() *Template {
+	return &Template{Template: "{ulid}{ext}"}
+}
+
+// DateSharded - "{yyyy}/{mm}/{dd}/{uuid}{ext}"
+func DateSharded() *Template {
+	return &Template{Template: "{yyyy}/{mm}/{dd}/{uuid}{ext}"}
+}
+
+// HashSharded - "{shard}/{uuid}{ext}"
+func HashSharded() *Template {
+	return &Template{Template: "{shard}/{uuid}{ext}"}
+}
+
+// ByName returns generator by its name, template is used for GeneratorTemplate only.
+// Not empty prefix is prepended to the generated paths
+func ByName(name, template, prefix string) (*Template, error) {
+	var t *Template
+
+	switch name {
+	case GeneratorUUID:
+		t = UUID()
+	case GeneratorULID:
+		t = ULID()
+	case GeneratorDate:
+		t = DateSharded()
+	case GeneratorHash:
+		t = HashSharded()
+	case GeneratorTemplate:
+		t = &Template{Template: template}
+	default:
+		return nil, fmt.Errorf("%s: %w", name, ErrUnknownGenerator)
+	}
+
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		t.Template = prefix + "/" + t.Template
+	}
+
+	return t, nil
+}
+
+func (t *Template) GeneratePath(fileName string, vars map[string]string) (p string, err error) {
+	ts := now().UTC()
+	id := uuid.New().String()
+	ext := strings.ToLower(path.Ext(fileName))
+
+	values := map[string]string{
+		"uuid":  id,
+		"yyyy":  ts.Format("2006"),
+		"mm":    ts.Format("01"),
+		"dd":    ts.Format("02"),
+		"hh":    ts.Format("15"),
+		"name":  strings.TrimSuffix(path.Base(fileName), path.Ext(fileName)),
+		"ext":   ext,
+		"shard": shard(id),
+	}
+
+	p = placeholder.ReplaceAllStringFunc(t.Template, func(m string) string {
+		name := m[1 : len(m)-1]
+
+		if v, ok := vars[name]; ok {
+			return v
+		}
+
+		if name == "ulid" {
+			return newULID(ts)
+		}
+
+		if v, ok := values[name]; ok {
+			return v
+		}
+
+		if err == nil {
+			err = fmt.Errorf("%w: %s", ErrMissingVar, name)
+		}
+
+		return m
+	})
+
+	return p, err
+}
+
+func shard(id string) string {
+	sum := sha1.Sum([]byte(id)) // nolint:gosec
+	h := hex.EncodeToString(sum[:2])
+
+	return h[:2] + "/" + h[2:]
+}
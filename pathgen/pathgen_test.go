@@ -0,0 +1,112 @@
+package pathgen
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+func TestGeneratePath(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 10, 17, 9, 30, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	uuidRe := `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`
+
+	flagtests := []struct {
+		name      string
+		generator *Template
+		fileName  string
+		vars      map[string]string
+		out       string
+	}{
+		{"uuid", UUID(), "photo.JPG", nil, `^` + uuidRe + `\.jpg$`},
+		{"ulid", ULID(), "photo.png", nil, `^[0-9A-HJKMNP-TV-Z]{26}\.png$`},
+		{"date", DateSharded(), "doc.pdf", nil, `^2026/10/17/` + uuidRe + `\.pdf$`},
+		{"hash", HashSharded(), "noext", nil, `^[0-9a-f]{2}/[0-9a-f]{2}/` + uuidRe + `$`},
+		{
+			"template", &Template{Template: "{tenant}/{yyyy}/{name}-{uuid}{ext}"}, "report.csv",
+			map[string]string{"tenant": "acme"}, `^acme/2026/report-` + uuidRe + `\.csv$`,
+		},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.generator.GeneratePath(tt.fileName, tt.vars)
+			if err != nil {
+				t.Fatalf("GeneratePath err: %q", err)
+			}
+
+			if !regexp.MustCompile(tt.out).MatchString(p) {
+				t.Errorf("got %q, want %q", p, tt.out)
+			}
+		})
+	}
+}
+
+func TestGeneratePathErrors(t *testing.T) {
+	_, err := (&Template{Template: "{tenant}/{uuid}"}).GeneratePath("a.jpg", nil)
+	if !errors.Is(err, ErrMissingVar) {
+		t.Errorf("got %v, want %v", err, ErrMissingVar)
+	}
+
+	_, err = ByName("random", "", "")
+	if !errors.Is(err, ErrUnknownGenerator) {
+		t.Errorf("got %v, want %v", err, ErrUnknownGenerator)
+	}
+
+	g, _ := ByName(GeneratorUUID, "", "/uploads/")
+	if g.Template != "uploads/{uuid}{ext}" {
+		t.Errorf("got %q, want prefixed template", g.Template)
+	}
+}
+
+func TestULIDOrder(t *testing.T) {
+	first := newULID(time.Unix(1000, 0))
+	second := newULID(time.Unix(1001, 0))
+
+	if len(first) != 26 || first[:10] >= second[:10] {
+		t.Errorf("ULIDs are not sortable: %q, %q", first, second)
+	}
+}
+
+func TestCreateCLinkAuto(t *testing.T) {
+	aStorage := core.New()
+	aStorage.AddStorage("files", local.New(&local.Config{
+		StorageKey: "files",
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+	}))
+	aStorage.SetPathGenerator("files", UUID())
+
+	tmp := filepath.Join(t.TempDir(), "upload-123")
+	ioutil.WriteFile(tmp, []byte("hello"), 0o644)
+
+	flagtests := []struct {
+		name    string
+		options []interface{}
+		ext     string
+	}{
+		{"temp name", nil, ""},
+		{"original name", []interface{}{core.FileName("photo.JPG")}, ".jpg"},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			cLink, err := aStorage.CreateCLinkAutoInStorage(tmp, "files", tt.options...)
+			if err != nil {
+				t.Fatalf("CreateCLinkAutoInStorage err: %q", err)
+			}
+
+			if ext := filepath.Ext(strings.TrimPrefix(cLink, "files:")); ext != tt.ext {
+				t.Errorf("got %q, want extension %q", cLink, tt.ext)
+			}
+		})
+	}
+}
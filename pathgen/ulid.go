@@ -0,0 +1,44 @@
+package pathgen
+
+import (
+	"crypto/rand"
+	"time"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns ULID: 48 bits of milliseconds and 80 random bits
+// in Crockford's base32, 26 characters
+func newULID(ts time.Time) string {
+	var b [16]byte
+
+	ms := uint64(ts.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+
+	rand.Read(b[6:]) // nolint:errcheck
+
+	// 128 bits are encoded as 130 bits with 2 leading zero bits
+	out := make([]byte, 26)
+
+	var (
+		acc  uint32
+		bits uint = 2
+		j    int
+	)
+
+	for _, v := range b {
+		acc = acc<<8 | uint32(v)
+		bits += 8
+
+		for bits >= 5 {
+			bits -= 5
+			out[j] = crockford[(acc>>bits)&0x1f]
+			j++
+		}
+	}
+
+	return string(out)
+}
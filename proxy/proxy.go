@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	Config struct {
+		Storage *core.AbstractStorage
+
+		// CLink returns cLink of the request, default PathCLink
+		CLink func(r *http.Request) (cLink string, err error)
+
+		// Authorize is called for every request, the error is returned with 403 status. Nil - no authorization
+		Authorize func(r *http.Request, cLink string) error
+
+		// Options returns GetURL options in Redirect mode and Open options otherwise, e.g. core.WithVersion
+		Options func(r *http.Request, cLink string) []interface{}
+
+		// responds with 302 to GetURL instead of streaming the object
+		Redirect bool
+	}
+
+	handler struct {
+		cfg Config
+	}
+)
+
+var ErrInvalidCLink = errors.New("invalid cLink")
+
+// New returns handler of GET and HEAD requests streaming objects of the storage
+// with Range, ETag and conditional requests, or redirecting to their URLs
+func New(cfg *Config) http.Handler {
+	h := &handler{cfg: *cfg}
+
+	if h.cfg.CLink == nil {
+		h.cfg.CLink = PathCLink
+	}
+
+	return h
+}
+
+// PathCLink - cLink from the request path "/<storage key>/<path>",
+// mount the handler with http.StripPrefix to serve it under a prefix
+func PathCLink(r *http.Request) (cLink string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || strings.Trim(parts[1], "/") == "" {
+		return "", fmt.Errorf("%s: %w", r.URL.Path, ErrInvalidCLink)
+	}
+
+	return common.PathToCLink(parts[0], parts[1]), nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	cLink, err := h.cfg.CLink(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if h.cfg.Authorize != nil {
+		if err = h.cfg.Authorize(r, cLink); err != nil {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+	}
+
+	var options []interface{}
+	if h.cfg.Options != nil {
+		options = h.cfg.Options(r, cLink)
+	}
+
+	if h.cfg.Redirect {
+		h.redirect(w, r, cLink, options)
+		return
+	}
+
+	h.stream(w, r, cLink, options)
+}
+
+func (h *handler) redirect(w http.ResponseWriter, r *http.Request, cLink string, options []interface{}) {
+	u := h.cfg.Storage.GetURL(cLink, options...)
+	if u == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+func (h *handler) stream(w http.ResponseWriter, r *http.Request, cLink string, options []interface{}) {
+	// Stat describes the current version only, other versions are sent as is
+	if core.ParseVersion(options...) != "" {
+		h.copy(w, r, cLink, options)
+		return
+	}
+
+	info, err := h.cfg.Storage.Stat(cLink)
+
+	// without Stat the size is unknown, the object is sent as is
+	if errors.Is(err, core.ErrNotSupported) {
+		h.copy(w, r, cLink, options)
+		return
+	}
+
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+
+	header := w.Header()
+
+	for name, value := range map[string]string{
+		"Content-Type":        info.ContentType,
+		"Content-Encoding":    info.ContentEncoding,
+		"Cache-Control":       info.CacheControl,
+		"Content-Disposition": info.ContentDisposition,
+		"Content-Language":    info.ContentLanguage,
+		"ETag":                quoteETag(info.ETag),
+	} {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+
+	content := &objectReader{
+		size: info.Size,
+		open: func(offset int64) (io.ReadCloser, error) {
+			return h.cfg.Storage.OpenRange(cLink, offset, -1, options...)
+		},
+	}
+	defer content.Close()
+
+	http.ServeContent(w, r, path.Base(info.Path), info.LastModified, content)
+}
+
+func (h *handler) copy(w http.ResponseWriter, r *http.Request, cLink string, options []interface{}) {
+	rc, err := h.cfg.Storage.Open(cLink, options...)
+	if err != nil {
+		httpError(w, r, err)
+		return
+	}
+	defer rc.Close()
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	io.Copy(w, rc) // nolint:errcheck
+}
+
+func httpError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, core.ErrObjectNotFound) || errors.Is(err, core.ErrStorageNotFound) || errors.Is(err, core.ErrCLinkError) {
+		http.NotFound(w, r)
+		return
+	}
+
+	log.Println("Failed to proxy object:", r.URL.Path, err)
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
+
+// quoteETag - ETag header value, some storages return ETag without quotes
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+
+	return `"` + etag + `"`
+}
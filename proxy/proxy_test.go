@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rosberry/storage/cache"
+	"github.com/rosberry/storage/compress"
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+var testStorageKey = "private"
+
+func TestProxy(t *testing.T) {
+	s := local.New(&local.Config{
+		StorageKey: testStorageKey,
+		Endpoint:   "http://url.com/files",
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+	})
+
+	aStorage := core.New()
+	aStorage.AddStorage(testStorageKey, s)
+
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, []byte("0123456789"), 0o644)
+	s.Store(tmp, "docs/a.txt", core.ContentType("text/csv"))
+
+	authorize := func(r *http.Request, cLink string) error {
+		if r.Header.Get("Authorization") != "token" {
+			return errors.New("forbidden")
+		}
+		return nil
+	}
+
+	h := http.StripPrefix("/files", New(&Config{Storage: aStorage, Authorize: authorize}))
+	redirect := New(&Config{Storage: aStorage, Redirect: true})
+
+	info, _ := s.Stat(testStorageKey + ":docs/a.txt")
+
+	flagtests := []struct {
+		name    string
+		handler http.Handler
+		method  string
+		target  string
+		header  map[string]string
+		code    int
+		body    string
+	}{
+		{"get", h, http.MethodGet, "/files/private/docs/a.txt", nil, http.StatusOK, "0123456789"},
+		{"range", h, http.MethodGet, "/files/private/docs/a.txt", map[string]string{"Range": "bytes=3-5"}, http.StatusPartialContent, "345"},
+		{"suffix range", h, http.MethodGet, "/files/private/docs/a.txt", map[string]string{"Range": "bytes=-2"}, http.StatusPartialContent, "89"},
+		{"etag", h, http.MethodGet, "/files/private/docs/a.txt", map[string]string{"If-None-Match": info.ETag}, http.StatusNotModified, ""},
+		{"modified", h, http.MethodGet, "/files/private/docs/a.txt", map[string]string{"If-Modified-Since": info.LastModified.UTC().Format(http.TimeFormat)}, http.StatusNotModified, ""},
+		{"head", h, http.MethodHead, "/files/private/docs/a.txt", nil, http.StatusOK, ""},
+		{"missing", h, http.MethodGet, "/files/private/docs/b.txt", nil, http.StatusNotFound, ""},
+		{"no path", h, http.MethodGet, "/files/private/", nil, http.StatusNotFound, ""},
+		{"unknown storage", h, http.MethodGet, "/files/public/docs/a.txt", nil, http.StatusNotFound, ""},
+		{"forbidden", h, http.MethodGet, "/files/private/docs/a.txt", map[string]string{"Authorization": "other"}, http.StatusForbidden, ""},
+		{"post", h, http.MethodPost, "/files/private/docs/a.txt", nil, http.StatusMethodNotAllowed, ""},
+		{"redirect", redirect, http.MethodGet, "/private/docs/a.txt", nil, http.StatusFound, ""},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			r.Header.Set("Authorization", "token")
+
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+
+			if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+				t.Errorf("got %d %q, want %d %q", w.Code, w.Body.String(), tt.code, tt.body)
+			}
+
+			if tt.code == http.StatusOK && w.Header().Get("Content-Type") != "text/csv" {
+				t.Errorf("unexpected headers: %v", w.Header())
+			}
+
+			if tt.code == http.StatusFound && w.Header().Get("Location") != "http://url.com/files/docs/a.txt" {
+				t.Errorf("unexpected location: %q", w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestProxyStoredBytes(t *testing.T) {
+	s := local.New(&local.Config{
+		StorageKey: testStorageKey,
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+		Versioning: true,
+	})
+
+	// cache over compress: Open decompresses, Stat describes the stored gzip
+	c := cache.New(&cache.Config{
+		StorageKey: testStorageKey,
+		StorageCtl: compress.New(&compress.Config{
+			StorageKey:   testStorageKey,
+			StorageCtl:   s,
+			ContentTypes: []string{"text/"},
+		}),
+		Dir: t.TempDir(),
+	})
+
+	aStorage := core.New()
+	aStorage.AddStorage(testStorageKey, c)
+
+	content := strings.Repeat("compressed content\n", 100)
+
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, []byte(content), 0o644)
+	c.Store(tmp, "a.txt")
+
+	w := httptest.NewRecorder()
+	New(&Config{Storage: aStorage}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/private/a.txt", nil))
+
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got %q, %v, want gzip body", w.Header().Get("Content-Encoding"), err)
+	}
+
+	if body, _ := ioutil.ReadAll(gr); string(body) != content {
+		t.Errorf("body is not equal to the content")
+	}
+
+	// the current version is replaced, the previous one is requested
+	ioutil.WriteFile(tmp, []byte("0123456789"), 0o644)
+	s.Store(tmp, "b.csv")
+	ioutil.WriteFile(tmp, []byte("abc"), 0o644)
+	s.Store(tmp, "b.csv")
+
+	versions, _ := s.ListVersions(testStorageKey + ":b.csv")
+
+	var previous string
+
+	for _, v := range versions {
+		if !v.IsLatest {
+			previous = v.VersionID
+		}
+	}
+
+	versioned := New(&Config{Storage: aStorage, Options: func(r *http.Request, cLink string) []interface{} {
+		return []interface{}{core.WithVersion(previous)}
+	}})
+
+	w = httptest.NewRecorder()
+	versioned.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/private/b.csv", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Errorf("got %d %q, want the previous version", w.Code, w.Body.String())
+	}
+}
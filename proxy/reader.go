@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var errNegativeOffset = errors.New("negative offset")
+
+// objectReader - io.ReadSeeker for http.ServeContent over the object of known size.
+// The object is opened from the offset on the first Read after Seek, so Range requests
+// read only the requested parts
+type objectReader struct {
+	size   int64
+	offset int64
+	open   func(offset int64) (io.ReadCloser, error)
+
+	rc io.ReadCloser
+}
+
+func (o *objectReader) Read(p []byte) (n int, err error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	if o.rc == nil {
+		if o.rc, err = o.open(o.offset); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = o.rc.Read(p)
+	o.offset += int64(n)
+
+	return n, err
+}
+
+func (o *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("%d: %w", offset, errNegativeOffset)
+	}
+
+	if offset != o.offset {
+		o.Close()
+		o.offset = offset
+	}
+
+	return offset, nil
+}
+
+func (o *objectReader) Close() error {
+	if o.rc == nil {
+		return nil
+	}
+
+	err := o.rc.Close()
+	o.rc = nil
+
+	return err // nolint:wrapcheck
+}
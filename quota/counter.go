@@ -0,0 +1,100 @@
+package quota
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	// Counter - store of usage per prefix, implement it to share usage between processes
+	Counter interface {
+		Usage(prefix string) (usage Usage, err error)
+		Add(prefix string, delta Usage) (err error)
+	}
+
+	Usage struct {
+		Bytes   int64
+		Objects int64
+	}
+
+	// ListCounter counts usage of the prefix by List of the storage and keeps it up to date by Add in memory.
+	// The usage is listed again after TTL, objects may change behind the wrapper (expiration, lifecycle rules)
+	ListCounter struct {
+		storage core.Storage
+		TTL     time.Duration // 0 - the usage is listed once
+
+		mu    sync.Mutex
+		usage map[string]counted
+	}
+
+	counted struct {
+		Usage
+		listedAt time.Time
+	}
+)
+
+// DefaultCounterTTL - TTL of ListCounter made by NewListCounter
+const DefaultCounterTTL = 10 * time.Minute
+
+func NewListCounter(s core.Storage) *ListCounter {
+	return &ListCounter{
+		storage: s,
+		TTL:     DefaultCounterTTL,
+		usage:   make(map[string]counted),
+	}
+}
+
+// Usage returns counted usage of the prefix, the storage is listed without holding the lock
+func (c *ListCounter) Usage(prefix string) (usage Usage, err error) {
+	c.mu.Lock()
+	cached, ok := c.usage[prefix]
+	c.mu.Unlock()
+
+	if ok && !c.expired(cached) {
+		return cached.Usage, nil
+	}
+
+	listedAt := time.Now()
+	usage = Usage{}
+
+	err = core.List(c.storage, prefix, func(info core.ObjectInfo) error {
+		usage.Bytes += info.Size
+		usage.Objects++
+
+		return nil
+	})
+	if err != nil {
+		return usage, err // nolint:wrapcheck
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// usage could be counted by concurrent call
+	if cached, ok := c.usage[prefix]; ok && !cached.listedAt.Before(listedAt) {
+		return cached.Usage, nil
+	}
+
+	c.usage[prefix] = counted{Usage: usage, listedAt: listedAt}
+
+	return usage, nil
+}
+
+func (c *ListCounter) Add(prefix string, delta Usage) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.usage[prefix]; ok {
+		cached.Bytes += delta.Bytes
+		cached.Objects += delta.Objects
+		c.usage[prefix] = cached
+	}
+
+	return nil
+}
+
+func (c *ListCounter) expired(cached counted) bool {
+	return c.TTL > 0 && time.Since(cached.listedAt) > c.TTL
+}
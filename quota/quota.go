@@ -0,0 +1,317 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	Config struct {
+		StorageKey    string
+		StorageCtl    core.Storage
+		MaxObjectSize int64 // bytes, 0 - unlimited
+		Rules         []Rule
+		Counter       Counter       // default - ListCounter of StorageCtl
+		CounterTTL    time.Duration // TTL of the default ListCounter, default DefaultCounterTTL
+	}
+
+	// Rule - limits for every path prefix matching Prefix.
+	// Segment in braces matches any path segment and gets its own limits,
+	// e.g. "users/{id}/" limits "users/1/" and "users/2/" separately.
+	// Empty Prefix limits the whole storage.
+	// The file is checked by its size before storing, the usage counts the stored size
+	Rule struct {
+		Prefix     string
+		MaxBytes   int64 // 0 - unlimited
+		MaxObjects int64 // 0 - unlimited
+	}
+
+	QuotaStorage struct {
+		cfg Config
+
+		// serializes checks with reservations, so concurrent uploads can't exceed limits together
+		mu sync.Mutex
+	}
+
+	// LimitError - detailed ErrQuotaExceeded
+	LimitError struct {
+		Prefix    string
+		Limit     string
+		Max       int64
+		Requested int64
+	}
+)
+
+const (
+	LimitObjectSize = "object size"
+	LimitBytes      = "bytes"
+	LimitObjects    = "objects"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+func New(cfg *Config) *QuotaStorage {
+	q := &QuotaStorage{
+		cfg: *cfg,
+	}
+
+	if q.cfg.Counter == nil {
+		counter := NewListCounter(q.cfg.StorageCtl)
+		if q.cfg.CounterTTL != 0 {
+			counter.TTL = q.cfg.CounterTTL
+		}
+
+		q.cfg.Counter = counter
+	}
+
+	return q
+}
+
+func (e *LimitError) Error() string {
+	if e.Prefix == "" {
+		return fmt.Sprintf("%v: %s: %d > %d", ErrQuotaExceeded, e.Limit, e.Requested, e.Max)
+	}
+
+	return fmt.Sprintf("%v: %s: %s: %d > %d", ErrQuotaExceeded, e.Prefix, e.Limit, e.Requested, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+func (q *QuotaStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	err = q.store(filePath, path, func() (string, error) {
+		cLink, err = q.cfg.StorageCtl.Store(filePath, path, options...)
+		return cLink, err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return cLink, nil
+}
+
+func (q *QuotaStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
+	return q.store(filePath, common.CLinkToPath(q.cfg.StorageKey, cLink), func() (string, error) {
+		return cLink, q.cfg.StorageCtl.StoreByCLink(filePath, cLink, options...)
+	})
+}
+
+func (q *QuotaStorage) GetURL(cLink string, options ...interface{}) string {
+	return q.cfg.StorageCtl.GetURL(cLink, options...)
+}
+
+func (q *QuotaStorage) Remove(cLink string) (err error) {
+	path := common.CLinkToPath(q.cfg.StorageKey, cLink)
+
+	info, statErr := core.Stat(q.cfg.StorageCtl, cLink)
+
+	err = q.cfg.StorageCtl.Remove(cLink)
+	if err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	if statErr == nil {
+		q.add(q.prefixes(path), Usage{Bytes: -info.Size, Objects: -1})
+	}
+
+	return nil
+}
+
+// Restore restores the object in the wrapped storage and counts it, see core.Restore
+func (q *QuotaStorage) Restore(cLink string) (err error) {
+	old, statErr := core.Stat(q.cfg.StorageCtl, cLink)
+
+	if err = core.Restore(q.cfg.StorageCtl, cLink); err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	q.recount(cLink, old, statErr == nil)
+
+	return nil
+}
+
+// RestoreVersion makes the version the latest one and counts the change of the size, see core.RestoreVersion
+func (q *QuotaStorage) RestoreVersion(cLink, versionID string) (err error) {
+	old, statErr := core.Stat(q.cfg.StorageCtl, cLink)
+
+	if err = core.RestoreVersion(q.cfg.StorageCtl, cLink, versionID); err != nil {
+		return err // nolint:wrapcheck
+	}
+
+	q.recount(cLink, old, statErr == nil)
+
+	return nil
+}
+
+// recount adds the difference between the old object and the current one to the usage
+func (q *QuotaStorage) recount(cLink string, old core.ObjectInfo, existed bool) {
+	info, err := core.Stat(q.cfg.StorageCtl, cLink)
+	if err != nil {
+		return
+	}
+
+	delta := Usage{Bytes: info.Size, Objects: 1}
+	if existed {
+		delta = Usage{Bytes: info.Size - old.Size}
+	}
+
+	q.add(q.prefixes(common.CLinkToPath(q.cfg.StorageKey, cLink)), delta)
+}
+
+func (q *QuotaStorage) GetCLink(path string) (cLink string) {
+	return q.cfg.StorageCtl.GetCLink(path)
+}
+
+func (q *QuotaStorage) Unwrap() core.Storage {
+	return q.cfg.StorageCtl
+}
+
+// Usage returns counted usage of the prefix
+func (q *QuotaStorage) Usage(prefix string) (Usage, error) {
+	return q.cfg.Counter.Usage(prefix) // nolint:wrapcheck
+}
+
+// store reserves usage of the file, stores it by fn and counts the stored size,
+// the reservation is rolled back if fn fails
+func (q *QuotaStorage) store(filePath, path string, fn func() (cLink string, err error)) error {
+	// the first count lists the storage, it should not block other uploads
+	q.count(path)
+
+	q.mu.Lock()
+	prefixes, size, delta, err := q.check(filePath, path)
+	if err == nil {
+		q.add(prefixes, delta)
+	}
+	q.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	cLink, err := fn()
+	if err != nil {
+		q.add(prefixes, Usage{Bytes: -delta.Bytes, Objects: -delta.Objects})
+		return err // nolint:wrapcheck
+	}
+
+	// wrapped storage could store another size (e.g. compressed), Remove and ListCounter count the stored one
+	if info, err := core.Stat(q.cfg.StorageCtl, cLink); err == nil && info.Size != size {
+		q.add(prefixes, Usage{Bytes: info.Size - size})
+	}
+
+	return nil
+}
+
+// check returns matched prefixes, the file size and usage change if the file fits all limits
+func (q *QuotaStorage) check(filePath, path string) (prefixes []string, size int64, delta Usage, err error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return nil, 0, delta, fmt.Errorf("failed to stat: %w", err)
+	}
+
+	size = fi.Size()
+
+	if q.cfg.MaxObjectSize > 0 && size > q.cfg.MaxObjectSize {
+		return nil, size, delta, &LimitError{Limit: LimitObjectSize, Max: q.cfg.MaxObjectSize, Requested: size}
+	}
+
+	delta = Usage{Bytes: size, Objects: 1}
+
+	// overwriting replaces the old object
+	if old, e := core.Stat(q.cfg.StorageCtl, q.GetCLink(path)); e == nil {
+		delta = Usage{Bytes: size - old.Size}
+	}
+
+	for _, r := range q.cfg.Rules {
+		prefix, ok := matchRule(r.Prefix, path)
+		if !ok {
+			continue
+		}
+
+		prefixes = append(prefixes, prefix)
+
+		if r.MaxBytes == 0 && r.MaxObjects == 0 {
+			continue
+		}
+
+		usage, err := q.cfg.Counter.Usage(prefix)
+		if err != nil {
+			return nil, size, delta, fmt.Errorf("failed to count usage of %s: %w", prefix, err)
+		}
+
+		if r.MaxBytes > 0 && delta.Bytes > 0 && usage.Bytes+delta.Bytes > r.MaxBytes {
+			return nil, size, delta, &LimitError{Prefix: prefix, Limit: LimitBytes, Max: r.MaxBytes, Requested: usage.Bytes + delta.Bytes}
+		}
+
+		if r.MaxObjects > 0 && delta.Objects > 0 && usage.Objects+delta.Objects > r.MaxObjects {
+			return nil, size, delta, &LimitError{Prefix: prefix, Limit: LimitObjects, Max: r.MaxObjects, Requested: usage.Objects + delta.Objects}
+		}
+	}
+
+	return prefixes, size, delta, nil
+}
+
+// count lists usage of the limited prefixes of the path, errors are returned by check
+func (q *QuotaStorage) count(path string) {
+	for _, r := range q.cfg.Rules {
+		if r.MaxBytes == 0 && r.MaxObjects == 0 {
+			continue
+		}
+
+		if prefix, ok := matchRule(r.Prefix, path); ok {
+			q.cfg.Counter.Usage(prefix) // nolint:errcheck
+		}
+	}
+}
+
+func (q *QuotaStorage) prefixes(path string) (prefixes []string) {
+	for _, r := range q.cfg.Rules {
+		if prefix, ok := matchRule(r.Prefix, path); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
+
+func (q *QuotaStorage) add(prefixes []string, delta Usage) {
+	for _, prefix := range prefixes {
+		q.cfg.Counter.Add(prefix, delta) // nolint:errcheck
+	}
+}
+
+// matchRule returns concrete prefix of the path matched by the rule prefix
+func matchRule(rulePrefix, path string) (prefix string, ok bool) {
+	rulePrefix = strings.TrimLeft(rulePrefix, "/")
+	if rulePrefix == "" {
+		return "", true
+	}
+
+	ruleSegments := strings.Split(strings.TrimRight(rulePrefix, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	// the last path segment is the file name, directory rule needs it too
+	if len(segments) <= len(ruleSegments) {
+		return "", false
+	}
+
+	for i, rs := range ruleSegments {
+		if strings.HasPrefix(rs, "{") && strings.HasSuffix(rs, "}") {
+			continue
+		}
+
+		if rs != segments[i] {
+			return "", false
+		}
+	}
+
+	return strings.Join(segments[:len(ruleSegments)], "/") + "/", true
+}
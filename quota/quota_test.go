@@ -0,0 +1,266 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rosberry/storage/compress"
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+var testStorageKey = "limited"
+
+func newTestStorage(t *testing.T, cfg Config) *QuotaStorage {
+	cfg.StorageKey = testStorageKey
+	cfg.StorageCtl = local.New(&local.Config{
+		StorageKey: testStorageKey,
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+	})
+
+	return New(&cfg)
+}
+
+func newTestFile(t *testing.T, size int) string {
+	tmp := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(tmp, make([]byte, size), 0o644)
+
+	return tmp
+}
+
+func TestMatchRule(t *testing.T) {
+	flagtests := []struct {
+		rule   string
+		path   string
+		prefix string
+		ok     bool
+	}{
+		{"", "users/1/a.jpg", "", true},
+		{"users/{id}/", "users/1/a.jpg", "users/1/", true},
+		{"users/{id}/", "/users/2/photos/a.jpg", "users/2/", true},
+		{"users/{id}/", "users/a.jpg", "", false},
+		{"users/{id}/", "groups/1/a.jpg", "", false},
+		{"tmp/", "tmp/a.jpg", "tmp/", true},
+		{"tmp/", "tmp2/a.jpg", "", false},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.rule+":"+tt.path, func(t *testing.T) {
+			prefix, ok := matchRule(tt.rule, tt.path)
+			if prefix != tt.prefix || ok != tt.ok {
+				t.Errorf("got %q, %v, want %q, %v", prefix, ok, tt.prefix, tt.ok)
+			}
+		})
+	}
+}
+
+func TestMaxObjectSize(t *testing.T) {
+	q := newTestStorage(t, Config{MaxObjectSize: 10})
+
+	if _, err := q.Store(newTestFile(t, 10), "small.bin"); err != nil {
+		t.Errorf("Store err: %q", err)
+	}
+
+	_, err := q.Store(newTestFile(t, 11), "big.bin")
+
+	var limitErr *LimitError
+	if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &limitErr) || limitErr.Limit != LimitObjectSize {
+		t.Errorf("got %v, want object size %v", err, ErrQuotaExceeded)
+	}
+}
+
+func TestPrefixLimits(t *testing.T) {
+	q := newTestStorage(t, Config{
+		Rules: []Rule{{Prefix: "users/{id}/", MaxBytes: 25, MaxObjects: 2}},
+	})
+
+	flagtests := []struct {
+		path  string
+		size  int
+		limit string
+	}{
+		{"users/1/a.bin", 10, ""},
+		{"users/1/b.bin", 10, ""},
+		{"users/1/c.bin", 1, LimitObjects},
+		{"users/1/b.bin", 15, ""},
+		{"users/1/b.bin", 16, LimitBytes},
+		{"users/2/a.bin", 20, ""},
+		{"shared/a.bin", 100, ""},
+	}
+
+	for _, tt := range flagtests {
+		_, err := q.Store(newTestFile(t, tt.size), tt.path)
+
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			if limitErr.Limit != tt.limit {
+				t.Errorf("%s: got %v, want %q", tt.path, err, tt.limit)
+			}
+		} else if err != nil || tt.limit != "" {
+			t.Errorf("%s: got %v, want %q", tt.path, err, tt.limit)
+		}
+	}
+
+	if err := q.Remove(q.GetCLink("users/1/a.bin")); err != nil {
+		t.Fatalf("Remove err: %q", err)
+	}
+
+	usage, _ := q.Usage("users/1/")
+	if usage.Bytes != 15 || usage.Objects != 1 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+
+	if _, err := q.Store(newTestFile(t, 10), "users/1/c.bin"); err != nil {
+		t.Errorf("Store after remove err: %q", err)
+	}
+}
+
+func TestConcurrentStore(t *testing.T) {
+	q := newTestStorage(t, Config{
+		Rules: []Rule{{MaxObjects: 3}},
+	})
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		stored int
+	)
+
+	for i := 0; i < 10; i++ {
+		file := newTestFile(t, 1)
+
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := q.Store(file, fmt.Sprintf("%d.bin", i)); err == nil {
+				mu.Lock()
+				stored++
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if stored != 3 {
+		t.Errorf("stored %d objects, want 3", stored)
+	}
+
+	if usage, _ := q.Usage(""); usage.Objects != 3 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+}
+
+func TestStoredSize(t *testing.T) {
+	inner := local.New(&local.Config{
+		StorageKey: testStorageKey,
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+	})
+
+	q := New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: compress.New(&compress.Config{
+			StorageKey:   testStorageKey,
+			StorageCtl:   inner,
+			PathPatterns: []string{"*.bin"},
+		}),
+		Rules: []Rule{{MaxBytes: 15000}},
+	})
+
+	var stored int64
+
+	// zeros are compressed, so the second file fits the limit too
+	for _, path := range []string{"a.bin", "b.bin"} {
+		cLink, err := q.Store(newTestFile(t, 10000), path)
+		if err != nil {
+			t.Fatalf("%s: Store err: %q", path, err)
+		}
+
+		info, err := inner.Stat(cLink)
+		if err != nil {
+			t.Fatalf("%s: Stat err: %q", path, err)
+		}
+
+		stored += info.Size
+	}
+
+	if usage, _ := q.Usage(""); usage.Bytes != stored || usage.Objects != 2 {
+		t.Errorf("usage %+v, want stored size %d", usage, stored)
+	}
+
+	for _, path := range []string{"a.bin", "b.bin"} {
+		if err := q.Remove(q.GetCLink(path)); err != nil {
+			t.Fatalf("%s: Remove err: %q", path, err)
+		}
+	}
+
+	if usage, _ := q.Usage(""); usage.Bytes != 0 || usage.Objects != 0 {
+		t.Errorf("unexpected usage after Remove: %+v", usage)
+	}
+}
+
+func TestCounterTTL(t *testing.T) {
+	q := newTestStorage(t, Config{
+		Rules:      []Rule{{MaxObjects: 1}},
+		CounterTTL: 10 * time.Millisecond,
+	})
+
+	cLink, err := q.Store(newTestFile(t, 1), "a.bin")
+	if err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	// removed behind the wrapper, e.g. expired
+	if err = q.Unwrap().Remove(cLink); err != nil {
+		t.Fatalf("Remove err: %q", err)
+	}
+
+	if _, err = q.Store(newTestFile(t, 1), "b.bin"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("got %v, want %v before refresh", err, ErrQuotaExceeded)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err = q.Store(newTestFile(t, 1), "b.bin"); err != nil {
+		t.Errorf("Store after refresh err: %q", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	q := New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: local.New(&local.Config{
+			StorageKey: testStorageKey,
+			Root:       t.TempDir(),
+			BufferSize: 32 * 1024,
+			Trash:      true,
+		}),
+		Rules: []Rule{{MaxBytes: 100}},
+	})
+
+	cLink, err := q.Store(newTestFile(t, 10), "a.bin")
+	if err != nil {
+		t.Fatalf("Store err: %q", err)
+	}
+
+	if err = q.Remove(cLink); err != nil {
+		t.Fatalf("Remove err: %q", err)
+	}
+
+	if err = core.Restore(q, cLink); err != nil {
+		t.Fatalf("Restore err: %q", err)
+	}
+
+	if usage, _ := q.Usage(""); usage.Bytes != 10 || usage.Objects != 1 {
+		t.Errorf("unexpected usage after Restore: %+v", usage)
+	}
+}
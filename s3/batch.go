@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// maxDeleteKeys is the limit of DeleteObjects request
+const maxDeleteKeys = 1000
+
+// RemoveMany removes objects by DeleteObjects requests with up to 1000 keys.
+// In trash mode objects are moved to trash one by one
+func (s *S3Storage) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	results = make([]core.DeleteResult, len(cLinks))
+
+	keys := make([]string, 0, len(cLinks))
+	indexes := make(map[string][]int, len(cLinks))
+
+	for i, cLink := range cLinks {
+		results[i].CLink = cLink
+
+		if s.cfg.Trash {
+			results[i].Err = s.Remove(cLink)
+			continue
+		}
+
+		path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+		if path == "" {
+			results[i].Err = fmt.Errorf("%s: %w", cLink, ErrFailedGetFilePath)
+			continue
+		}
+
+		key := common.PathToInternalPath(s.cfg.Prefix, path)
+		if _, ok := indexes[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		indexes[key] = append(indexes[key], i)
+	}
+
+	for start := 0; start < len(keys); start += maxDeleteKeys {
+		end := start + maxDeleteKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		for key, err := range s.deleteObjects(keys[start:end]) {
+			for _, i := range indexes[key] {
+				results[i].Err = err
+			}
+		}
+	}
+
+	return results
+}
+
+// RemovePrefix removes all objects which path starts with prefix, page by page
+func (s *S3Storage) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	if strings.Trim(prefix, "/") == "" {
+		return nil, core.ErrEmptyPrefix
+	}
+
+	if s.cfg.Trash {
+		var cLinks []string
+
+		err = s.List(prefix, func(info core.ObjectInfo) error {
+			cLinks = append(cLinks, info.CLink)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return s.RemoveMany(cLinks), nil
+	}
+
+	svc := s3.New(s.getSession())
+
+	err = svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.cfg.BucketName),
+		Prefix:  aws.String(common.PrefixToInternalPrefix(s.cfg.Prefix, prefix)),
+		MaxKeys: aws.Int64(maxDeleteKeys),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		keys := make([]string, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+
+		errs := s.deleteObjects(keys)
+
+		for _, key := range keys {
+			results = append(results, core.DeleteResult{
+				CLink: common.PathToCLink(s.cfg.StorageKey, common.InternalPathToPath(s.cfg.Prefix, key)),
+				Err:   errs[key],
+			})
+		}
+
+		return true
+	})
+	if err != nil {
+		return results, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	return results, nil
+}
+
+// deleteObjects removes up to 1000 keys, the result has an entry for every key
+func (s *S3Storage) deleteObjects(keys []string) map[string]error {
+	errs := make(map[string]error, len(keys))
+	if len(keys) == 0 {
+		return errs
+	}
+
+	objects := make([]*s3.ObjectIdentifier, len(keys))
+	for i, key := range keys {
+		objects[i] = &s3.ObjectIdentifier{Key: aws.String(key)}
+		errs[key] = nil
+	}
+
+	svc := s3.New(s.getSession())
+
+	out, err := svc.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Delete: &s3.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to delete objects: %w", err)
+		for _, key := range keys {
+			errs[key] = err
+		}
+
+		return errs
+	}
+
+	for _, e := range out.Errors {
+		errs[aws.StringValue(e.Key)] = fmt.Errorf("%s: %w", aws.StringValue(e.Code), errors.New(aws.StringValue(e.Message)))
+	}
+
+	return errs
+}
@@ -0,0 +1,116 @@
+package s3
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/rosberry/storage/common"
+)
+
+const noSuchLifecycleConfiguration = "NoSuchLifecycleConfiguration"
+
+var expiryDateRe = regexp.MustCompile(`expiry-date="([^"]+)"`)
+
+// SetupExpirationRules adds lifecycle rules to the bucket for objects stored with core.ExpiresAt:
+// one rule for every day up to MaxExpirationDays and the rule purging trash in trash mode.
+// Rules not created by the storage are kept
+func (s *S3Storage) SetupExpirationRules() (err error) {
+	svc := s3.New(s.getSession())
+
+	var rules []*s3.LifecycleRule
+
+	out, err := svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.cfg.BucketName),
+	})
+	if aErr, ok := err.(awserr.Error); ok && aErr.Code() == noSuchLifecycleConfiguration {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lifecycle configuration: %w", err)
+	}
+
+	if out != nil {
+		for _, r := range out.Rules {
+			if !strings.HasPrefix(aws.StringValue(r.ID), common.ExpirationRulePrefix) {
+				rules = append(rules, r)
+			}
+		}
+	}
+
+	for days := 1; days <= s.maxExpirationDays(); days++ {
+		rules = append(rules, &s3.LifecycleRule{
+			ID:     aws.String(common.ExpirationRuleID(days)),
+			Status: aws.String(s3.ExpirationStatusEnabled),
+			Filter: &s3.LifecycleRuleFilter{
+				Tag: &s3.Tag{
+					Key:   aws.String(common.ExpirationTag),
+					Value: aws.String(strconv.Itoa(days)),
+				},
+			},
+			Expiration: &s3.LifecycleExpiration{
+				Days: aws.Int64(int64(days)),
+			},
+		})
+	}
+
+	if s.cfg.Trash {
+		rules = append(rules, s.trashRule())
+	}
+
+	_, err = svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: rules,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put lifecycle configuration: %w", err)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) maxExpirationDays() int {
+	if s.cfg.MaxExpirationDays > 0 {
+		return s.cfg.MaxExpirationDays
+	}
+
+	return common.DefaultMaxExpirationDays
+}
+
+// expirationTagging returns tagging for the upload, nil for zero expiresAt
+func (s *S3Storage) expirationTagging(expiresAt time.Time) (*string, error) {
+	if expiresAt.IsZero() {
+		return nil, nil
+	}
+
+	days, err := common.ExpirationDays(expiresAt, s.maxExpirationDays())
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+
+	return aws.String(url.Values{common.ExpirationTag: {strconv.Itoa(days)}}.Encode()), nil
+}
+
+// parseExpiration returns expiry date from x-amz-expiration header:
+// expiry-date="Fri, 23 Dec 2012 00:00:00 GMT", rule-id="picture-deletion-rule"
+func parseExpiration(header string) time.Time {
+	m := expiryDateRe.FindStringSubmatch(header)
+	if m == nil {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC1123, m[1])
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
@@ -3,10 +3,17 @@ package s3
 import (
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
 )
 
 type (
@@ -18,11 +25,24 @@ type (
 		BucketName      string
 		Prefix          string
 		NoSSL           bool
+		PathPolicy      *common.PathPolicy // default common.DefaultPathPolicy
+
+		// max days of core.ExpiresAt, default common.DefaultMaxExpirationDays, see SetupExpirationRules
+		MaxExpirationDays int
+
+		// Remove moves objects to .trash/ prefix, they are purged after TrashRetention
+		// (default common.DefaultTrashRetention) by PurgeTrash or lifecycle rule, see SetupExpirationRules
+		Trash          bool
+		TrashRetention time.Duration
+
+		// GetURL requests ACL of the object without core.Visibility option and presigns URLs of private objects
+		CheckVisibility bool
 	}
 
 	S3Storage struct { // nolint:golint
 		cfg    Config
 		scheme string
+		policy common.PathPolicy
 	}
 )
 
@@ -31,6 +51,10 @@ const (
 	SchemeHTTPWithoutSSL = "http"
 
 	S3HostTemplate = "%s.s3.amazonaws.com"
+
+	presignedURLLifeTime = 24 * time.Hour
+
+	allUsersURI = "http://acs.amazonaws.com/groups/global/AllUsers"
 )
 
 var (
@@ -47,28 +71,57 @@ func New(cfg *Config) *S3Storage {
 	return &S3Storage{
 		cfg:    *cfg,
 		scheme: scheme,
+		policy: common.GetPathPolicy(cfg.PathPolicy),
 	}
 }
 
-func (s *S3Storage) Store(filePath, path string) (cLink string, err error) {
-	return s.storeByPath(filePath, path)
+func (s *S3Storage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	return s.storeByPath(filePath, path, core.ParseStoreOptions(options...))
 }
 
-func (s *S3Storage) StoreByCLink(filePath, cLink string) (err error) {
+func (s *S3Storage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
 	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
 
-	_, err = s.storeByPath(filePath, path)
+	_, err = s.storeByPath(filePath, path, core.ParseStoreOptions(options...))
 
 	return err
 }
 
+// GetURL returns unsigned URL, presigned URL is returned for core.VisibilityPrivate option
+// or for private objects with CheckVisibility config (the ACL is requested from the bucket).
+// URL for the version (core.WithVersion) is always presigned
 func (s *S3Storage) GetURL(cLink string, options ...interface{}) string {
 	u, err := s.prepareURL(cLink)
 	if err != nil {
 		return ""
 	}
 
-	return u.String()
+	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	internalPath := common.PathToInternalPath(s.cfg.Prefix, path)
+	versionID := core.ParseVersion(options...)
+
+	v := core.ParseVisibility(options...)
+	if versionID != "" {
+		v = core.VisibilityPrivate
+	}
+
+	if v == "" && s.cfg.CheckVisibility {
+		if v, err = s.visibility(internalPath); err != nil {
+			log.Println("Failed get visibility:", err)
+		}
+	}
+
+	if v != core.VisibilityPrivate {
+		return u.String()
+	}
+
+	URL, err := s.presignGetObject(internalPath, versionID)
+	if err != nil {
+		log.Println("Failed presign URL:", err)
+		return ""
+	}
+
+	return URL
 }
 
 func (s *S3Storage) Remove(cLink string) (err error) {
@@ -82,6 +135,10 @@ func (s *S3Storage) Remove(cLink string) (err error) {
 		return fmt.Errorf("%s: %s: %w", cLink, path, ErrFailedGetFilePath)
 	}
 
+	if s.cfg.Trash {
+		return s.trash(path)
+	}
+
 	svc := s3.New(s.getSession())
 	_, err = svc.DeleteObject(&s3.DeleteObjectInput{
 		Bucket: aws.String(s.cfg.BucketName),
@@ -92,5 +149,215 @@ func (s *S3Storage) Remove(cLink string) (err error) {
 }
 
 func (s *S3Storage) GetCLink(path string) (cLink string) {
+	path, err := s.policy.Normalize(path)
+	if err != nil {
+		return ""
+	}
+
 	return common.PathToCLink(s.cfg.StorageKey, path)
 }
+
+func (s *S3Storage) Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	return s.getObject(cLink, nil, options)
+}
+
+// OpenRange reads the part of the object by Range header, length < 0 - to the end
+func (s *S3Storage) OpenRange(cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error) {
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		if length == 0 {
+			return http.NoBody, nil
+		}
+
+		byteRange += strconv.FormatInt(offset+length-1, 10)
+	}
+
+	return s.getObject(cLink, aws.String(byteRange), options)
+}
+
+func (s *S3Storage) getObject(cLink string, byteRange *string, options []interface{}) (rc io.ReadCloser, err error) {
+	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	if path == "" {
+		return nil, fmt.Errorf("%s: %w", cLink, ErrFailedGetFilePath)
+	}
+
+	svc := s3.New(s.getSession())
+
+	out, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket:    aws.String(s.cfg.BucketName),
+		Key:       aws.String(common.PathToInternalPath(s.cfg.Prefix, path)),
+		VersionId: optionalString(core.ParseVersion(options...)),
+		Range:     byteRange,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return out.Body, nil
+}
+
+func (s *S3Storage) Stat(cLink string) (info core.ObjectInfo, err error) {
+	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	if path == "" {
+		return info, fmt.Errorf("%s: %w", cLink, ErrFailedGetFilePath)
+	}
+
+	svc := s3.New(s.getSession())
+
+	out, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(common.PathToInternalPath(s.cfg.Prefix, path)),
+	})
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return info, fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return info, fmt.Errorf("failed to head object: %w", err)
+	}
+
+	return core.ObjectInfo{
+		CLink:              common.PathToCLink(s.cfg.StorageKey, path),
+		Path:               path,
+		Size:               aws.Int64Value(out.ContentLength),
+		ContentType:        aws.StringValue(out.ContentType),
+		ContentEncoding:    aws.StringValue(out.ContentEncoding),
+		CacheControl:       aws.StringValue(out.CacheControl),
+		ContentDisposition: aws.StringValue(out.ContentDisposition),
+		ContentLanguage:    aws.StringValue(out.ContentLanguage),
+		Metadata:           lowerKeys(out.Metadata),
+		ETag:               aws.StringValue(out.ETag),
+		LastModified:       aws.TimeValue(out.LastModified),
+		ExpiresAt:          parseExpiration(aws.StringValue(out.Expiration)),
+	}, nil
+}
+
+// UpdateMetadata replaces headers and user metadata by copying the object onto itself,
+// see core.MergeMetadata
+func (s *S3Storage) UpdateMetadata(cLink string, options ...interface{}) (err error) {
+	info, err := s.Stat(cLink)
+	if err != nil {
+		return err
+	}
+
+	opts := core.MergeMetadata(info, options...)
+	internalPath := common.PathToInternalPath(s.cfg.Prefix, info.Path)
+
+	// copy gets the default ACL, public access is kept explicitly
+	v, err := s.visibility(internalPath)
+	if err != nil {
+		return err
+	}
+
+	if v == core.VisibilityPublic {
+		opts.Visibility = v
+	}
+
+	acl, err := cannedACL(opts.Visibility)
+	if err != nil {
+		return err
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:             aws.String(s.cfg.BucketName),
+		Key:                aws.String(internalPath),
+		CopySource:         aws.String(s.copySource(internalPath)),
+		MetadataDirective:  aws.String(s3.MetadataDirectiveReplace),
+		ACL:                acl,
+		ContentType:        optionalString(opts.ContentType),
+		ContentEncoding:    optionalString(opts.ContentEncoding),
+		CacheControl:       optionalString(opts.CacheControl),
+		ContentDisposition: optionalString(opts.ContentDisposition),
+		ContentLanguage:    optionalString(opts.ContentLanguage),
+	}
+
+	if len(opts.UserMetadata) > 0 {
+		input.Metadata = aws.StringMap(opts.UserMetadata)
+	}
+
+	svc := s3.New(s.getSession())
+
+	if _, err = svc.CopyObject(input); err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	return nil
+}
+
+// GetVisibility is public if anyone can read the object by ACL
+func (s *S3Storage) GetVisibility(cLink string) (v core.Visibility, err error) {
+	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	if path == "" {
+		return "", fmt.Errorf("%s: %w", cLink, ErrFailedGetFilePath)
+	}
+
+	return s.visibility(common.PathToInternalPath(s.cfg.Prefix, path))
+}
+
+// SetVisibility changes ACL of the object: public-read or private
+func (s *S3Storage) SetVisibility(cLink string, v core.Visibility) (err error) {
+	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	if path == "" {
+		return fmt.Errorf("%s: %w", cLink, ErrFailedGetFilePath)
+	}
+
+	acl, err := cannedACL(v)
+	if err != nil {
+		return err
+	}
+
+	if acl == nil {
+		return fmt.Errorf("%s: empty visibility: %w", cLink, core.ErrInvalidVisibility)
+	}
+
+	svc := s3.New(s.getSession())
+
+	_, err = svc.PutObjectAcl(&s3.PutObjectAclInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(common.PathToInternalPath(s.cfg.Prefix, path)),
+		ACL:    acl,
+	})
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set ACL: %w", err)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) List(prefix string, fn func(info core.ObjectInfo) error) (err error) {
+	svc := s3.New(s.getSession())
+
+	var fnErr error
+
+	err = svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.BucketName),
+		Prefix: aws.String(common.PrefixToInternalPrefix(s.cfg.Prefix, prefix)),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			path := common.InternalPathToPath(s.cfg.Prefix, aws.StringValue(obj.Key))
+			if s.cfg.Trash && common.IsTrashPath(path) {
+				continue
+			}
+
+			fnErr = fn(core.ObjectInfo{
+				CLink:        common.PathToCLink(s.cfg.StorageKey, path),
+				Path:         path,
+				Size:         aws.Int64Value(obj.Size),
+				ETag:         aws.StringValue(obj.ETag),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+			if fnErr != nil {
+				return false
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	return fnErr
+}
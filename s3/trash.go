@@ -0,0 +1,177 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// visibilityTag keeps public access of the object in trash, the copy in trash is private
+const visibilityTag = "storage-visibility"
+
+// Restore copies removed object from trash back to its path
+func (s *S3Storage) Restore(cLink string) (err error) {
+	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	if path == "" {
+		return fmt.Errorf("%s: %w", cLink, ErrFailedGetFilePath)
+	}
+
+	internalPath := common.PathToInternalPath(s.cfg.Prefix, path)
+	trashPath := common.PathToInternalPath(s.cfg.Prefix, common.TrashPath(path))
+
+	svc := s3.New(s.getSession())
+
+	tagging, err := svc.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(trashPath),
+	})
+	if isNotFound(err) {
+		return fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get object tagging: %w", err)
+	}
+
+	_, err = svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(internalPath),
+	})
+	if err == nil {
+		return fmt.Errorf("%s: %w", cLink, core.ErrObjectExists)
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("failed to head object: %w", err)
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:           aws.String(s.cfg.BucketName),
+		Key:              aws.String(internalPath),
+		CopySource:       aws.String(s.copySource(trashPath)),
+		TaggingDirective: aws.String(s3.TaggingDirectiveReplace),
+	}
+
+	for _, tag := range tagging.TagSet {
+		if aws.StringValue(tag.Key) == visibilityTag && aws.StringValue(tag.Value) == string(core.VisibilityPublic) {
+			input.ACL = aws.String(s3.ObjectCannedACLPublicRead)
+		}
+	}
+
+	if _, err = svc.CopyObject(input); err != nil {
+		return fmt.Errorf("failed to restore object: %w", err)
+	}
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(trashPath),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove object from trash: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeTrash removes objects kept in trash longer than TrashRetention.
+// Use it by schedule or let the bucket do it by SetupExpirationRules
+func (s *S3Storage) PurgeTrash() (purged int, err error) {
+	deadline := time.Now().Add(-common.GetTrashRetention(s.cfg.TrashRetention))
+	svc := s3.New(s.getSession())
+
+	var keys []*string
+
+	err = svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.BucketName),
+		Prefix: aws.String(common.PrefixToInternalPrefix(s.cfg.Prefix, common.TrashDir+"/")),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			if aws.TimeValue(obj.LastModified).Before(deadline) {
+				keys = append(keys, obj.Key)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	for _, key := range keys {
+		_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(s.cfg.BucketName),
+			Key:    key,
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge trash: %w", err)
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// trash copies object to trash and removes it, LastModified of the copy is the deletion time
+func (s *S3Storage) trash(path string) error {
+	internalPath := common.PathToInternalPath(s.cfg.Prefix, path)
+
+	v, err := s.visibility(internalPath)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("%s: %w", path, core.ErrObjectNotFound)
+		}
+
+		return err
+	}
+
+	svc := s3.New(s.getSession())
+
+	// tags are replaced, so expiration rules do not remove the object from trash
+	_, err = svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:           aws.String(s.cfg.BucketName),
+		Key:              aws.String(common.PathToInternalPath(s.cfg.Prefix, common.TrashPath(path))),
+		CopySource:       aws.String(s.copySource(internalPath)),
+		TaggingDirective: aws.String(s3.TaggingDirectiveReplace),
+		Tagging:          aws.String(url.Values{visibilityTag: {string(v)}}.Encode()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move object to trash: %w", err)
+	}
+
+	_, err = svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(internalPath),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove object: %w", err)
+	}
+
+	return nil
+}
+
+// trashRule is the lifecycle rule removing objects from trash after TrashRetention
+func (s *S3Storage) trashRule() *s3.LifecycleRule {
+	return &s3.LifecycleRule{
+		ID:     aws.String(common.ExpirationRulePrefix + common.TrashDir[1:]),
+		Status: aws.String(s3.ExpirationStatusEnabled),
+		Filter: &s3.LifecycleRuleFilter{
+			Prefix: aws.String(common.PrefixToInternalPrefix(s.cfg.Prefix, common.TrashDir+"/")),
+		},
+		Expiration: &s3.LifecycleExpiration{
+			Days: aws.Int64(int64(common.RetentionDays(s.cfg.TrashRetention))),
+		},
+	}
+}
+
+func isNotFound(err error) bool {
+	var reqErr awserr.RequestFailure
+
+	return errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
 )
 
 func (s *S3Storage) getSession() *session.Session {
@@ -19,31 +22,67 @@ func (s *S3Storage) getSession() *session.Session {
 	}))
 }
 
-func (s *S3Storage) storeByPath(filePath string, path string) (cLink string, err error) {
-	err = s.storeByInternalPath(filePath, common.PathToInternalPath(s.cfg.Prefix, path))
+func (s *S3Storage) storeByPath(filePath string, path string, opts core.StoreOptions) (cLink string, err error) {
+	normalized, err := s.policy.Normalize(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to store %s: %w", path, err)
 	}
 
-	cLink = s.GetCLink(path)
+	path = normalized
+
+	if s.cfg.Trash && common.IsTrashPath(path) {
+		return "", fmt.Errorf("failed to store %s: %w", path, common.ErrInvalidPath)
+	}
+
+	err = s.storeByInternalPath(filePath, common.PathToInternalPath(s.cfg.Prefix, path), opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", path, err)
+	}
+
+	cLink = common.PathToCLink(s.cfg.StorageKey, path)
 
 	return
 }
 
-func (s *S3Storage) storeByInternalPath(filePath, internalPath string) (err error) {
+func (s *S3Storage) storeByInternalPath(filePath, internalPath string, opts core.StoreOptions) (err error) {
 	uploader := s3manager.NewUploader(s.getSession())
 
 	f, _ := os.Open(filePath)
 	defer f.Close()
 
-	mimetype := common.GetFileContentType(f)
+	acl, err := cannedACL(opts.Visibility)
+	if err != nil {
+		return err
+	}
 
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Bucket:      aws.String(s.cfg.BucketName),
-		Key:         aws.String(internalPath),
-		Body:        f,
-		ContentType: aws.String(mimetype),
-	})
+	tagging, err := s.expirationTagging(opts.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	mimetype := opts.ContentType
+	if mimetype == "" {
+		mimetype = common.GetFileContentType(f)
+	}
+
+	input := &s3manager.UploadInput{
+		Bucket:             aws.String(s.cfg.BucketName),
+		Key:                aws.String(internalPath),
+		Body:               f,
+		ContentType:        aws.String(mimetype),
+		ContentEncoding:    optionalString(opts.ContentEncoding),
+		CacheControl:       optionalString(opts.CacheControl),
+		ContentDisposition: optionalString(opts.ContentDisposition),
+		ContentLanguage:    optionalString(opts.ContentLanguage),
+		ACL:                acl,
+		Tagging:            tagging,
+	}
+
+	if len(opts.UserMetadata) > 0 {
+		input.Metadata = aws.StringMap(opts.UserMetadata)
+	}
+
+	_, err = uploader.Upload(input)
 	if err != nil {
 		return fmt.Errorf("failed to upload file: %w", err)
 	}
@@ -51,6 +90,100 @@ func (s *S3Storage) storeByInternalPath(filePath, internalPath string) (err erro
 	return nil
 }
 
+// visibility is public if anyone can read the object
+func (s *S3Storage) visibility(internalPath string) (v core.Visibility, err error) {
+	svc := s3.New(s.getSession())
+
+	out, err := svc.GetObjectAcl(&s3.GetObjectAclInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Key:    aws.String(internalPath),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get ACL: %w", err)
+	}
+
+	if isPublic(out.Grants) {
+		return core.VisibilityPublic, nil
+	}
+
+	return core.VisibilityPrivate, nil
+}
+
+// isPublic reports whether anyone can read the object
+func isPublic(grants []*s3.Grant) bool {
+	for _, g := range grants {
+		if g.Grantee == nil || aws.StringValue(g.Grantee.URI) != allUsersURI {
+			continue
+		}
+
+		switch aws.StringValue(g.Permission) {
+		case s3.PermissionRead, s3.PermissionFullControl:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *S3Storage) presignGetObject(internalPath, versionID string) (URL string, err error) {
+	svc := s3.New(s.getSession())
+
+	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:    aws.String(s.cfg.BucketName),
+		Key:       aws.String(internalPath),
+		VersionId: optionalString(versionID),
+	})
+
+	URL, err = req.Presign(presignedURLLifeTime)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign: %w", err)
+	}
+
+	return URL, nil
+}
+
+// cannedACL returns ACL for visibility, nil for empty visibility
+func cannedACL(v core.Visibility) (*string, error) {
+	switch v {
+	case "":
+		return nil, nil
+	case core.VisibilityPublic:
+		return aws.String(s3.ObjectCannedACLPublicRead), nil
+	case core.VisibilityPrivate:
+		return aws.String(s3.ObjectCannedACLPrivate), nil
+	}
+
+	return nil, fmt.Errorf("%s: %w", v, core.ErrInvalidVisibility)
+}
+
+// copySource is the escaped "bucket/key" for CopyObject
+func (s *S3Storage) copySource(internalPath string) string {
+	return (&url.URL{Path: s.cfg.BucketName + "/" + internalPath}).EscapedPath()
+}
+
+// optionalString returns nil for empty string, so the header is not sent
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+
+	return aws.String(v)
+}
+
+// lowerKeys returns user metadata with lower case keys, as it is returned by yos and local storages
+func lowerKeys(m map[string]*string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = aws.StringValue(v)
+	}
+
+	return out
+}
+
 func (s *S3Storage) prepareURL(cLink string) (u *url.URL, err error) {
 	var uc *url.URL
 
@@ -0,0 +1,115 @@
+package s3
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// ListVersions lists versions and delete markers of the object, the latest first.
+// Versioning should be enabled in the bucket
+func (s *S3Storage) ListVersions(cLink string) (versions []core.VersionInfo, err error) {
+	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	if path == "" {
+		return nil, fmt.Errorf("%s: %w", cLink, ErrFailedGetFilePath)
+	}
+
+	internalPath := common.PathToInternalPath(s.cfg.Prefix, path)
+	svc := s3.New(s.getSession())
+
+	err = svc.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.cfg.BucketName),
+		Prefix: aws.String(internalPath),
+	}, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, v := range page.Versions {
+			if aws.StringValue(v.Key) != internalPath {
+				continue
+			}
+
+			versions = append(versions, core.VersionInfo{
+				ObjectInfo: core.ObjectInfo{
+					CLink:        common.PathToCLink(s.cfg.StorageKey, path),
+					Path:         path,
+					Size:         aws.Int64Value(v.Size),
+					ETag:         aws.StringValue(v.ETag),
+					LastModified: aws.TimeValue(v.LastModified),
+				},
+				VersionID: aws.StringValue(v.VersionId),
+				IsLatest:  aws.BoolValue(v.IsLatest),
+			})
+		}
+
+		for _, m := range page.DeleteMarkers {
+			if aws.StringValue(m.Key) != internalPath {
+				continue
+			}
+
+			versions = append(versions, core.VersionInfo{
+				ObjectInfo: core.ObjectInfo{
+					CLink:        common.PathToCLink(s.cfg.StorageKey, path),
+					Path:         path,
+					LastModified: aws.TimeValue(m.LastModified),
+				},
+				VersionID:      aws.StringValue(m.VersionId),
+				IsLatest:       aws.BoolValue(m.IsLatest),
+				IsDeleteMarker: true,
+			})
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+
+	return versions, nil
+}
+
+// RestoreVersion copies the version over the object, the copy becomes the latest version.
+// Public access of the version is kept
+func (s *S3Storage) RestoreVersion(cLink, versionID string) (err error) {
+	path := common.CLinkToPath(s.cfg.StorageKey, cLink)
+	if path == "" {
+		return fmt.Errorf("%s: %w", cLink, ErrFailedGetFilePath)
+	}
+
+	internalPath := common.PathToInternalPath(s.cfg.Prefix, path)
+	svc := s3.New(s.getSession())
+
+	acl, err := svc.GetObjectAcl(&s3.GetObjectAclInput{
+		Bucket:    aws.String(s.cfg.BucketName),
+		Key:       aws.String(internalPath),
+		VersionId: aws.String(versionID),
+	})
+	if isNotFound(err) {
+		return fmt.Errorf("%s: version %s: %w", cLink, versionID, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get ACL: %w", err)
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(s.cfg.BucketName),
+		Key:        aws.String(internalPath),
+		CopySource: aws.String(s.copySource(internalPath) + "?versionId=" + url.QueryEscape(versionID)),
+	}
+
+	if isPublic(acl.Grants) {
+		input.ACL = aws.String(s3.ObjectCannedACLPublicRead)
+	}
+
+	if _, err = svc.CopyObject(input); err != nil {
+		return fmt.Errorf("failed to restore version: %w", err)
+	}
+
+	return nil
+}
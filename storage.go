@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"io"
+
 	"github.com/rosberry/storage/core"
 )
 
@@ -17,13 +19,36 @@ func GetStorage(storageKey string) (s core.Storage, err error) {
 }
 
 //CreateCLinkInStorage - save file and create clink in selected storage by storageKey
-func CreateCLinkInStorage(filePath, path, storageKey string) (cLink string, err error) {
-	return aStorage.CreateCLinkInStorage(filePath, path, storageKey)
+func CreateCLinkInStorage(filePath, path, storageKey string, options ...interface{}) (cLink string, err error) {
+	return aStorage.CreateCLinkInStorage(filePath, path, storageKey, options...)
 }
 
 //CreateCLink - save file and create cLink in default storage
-func CreateCLink(filePath, path string) (cLink string, err error) {
-	return aStorage.CreateCLink(filePath, path)
+func CreateCLink(filePath, path string, options ...interface{}) (cLink string, err error) {
+	return aStorage.CreateCLink(filePath, path, options...)
+}
+
+//CreateCLinkAutoInStorage - save file by generated path and create cLink in selected storage by storageKey
+func CreateCLinkAutoInStorage(filePath, storageKey string, options ...interface{}) (cLink string, err error) {
+	return aStorage.CreateCLinkAutoInStorage(filePath, storageKey, options...)
+}
+
+//CreateCLinkAuto - save file by generated path and create cLink in default storage
+func CreateCLinkAuto(filePath string, options ...interface{}) (cLink string, err error) {
+	return aStorage.CreateCLinkAuto(filePath, options...)
+}
+
+//SetPathGenerator - set path generator for CreateCLinkAuto and PrepareCLinkAuto in the storage
+func SetPathGenerator(storageKey string, generator core.PathGenerator) (err error) {
+	return aStorage.SetPathGenerator(storageKey, generator)
+}
+
+func PrepareCLinkAutoInStorage(fileName, storageKey string, options ...interface{}) (cLink string, err error) {
+	return aStorage.PrepareCLinkAutoInStorage(fileName, storageKey, options...)
+}
+
+func PrepareCLinkAuto(fileName string, options ...interface{}) (cLink string, err error) {
+	return aStorage.PrepareCLinkAuto(fileName, options...)
 }
 
 func PrepareCLinkInStorage(path, storageKey string) (cLink string, err error) {
@@ -49,11 +74,76 @@ func SetDefaultStorage(storageKey string) (err error) {
 	return aStorage.SetDefaultStorage(storageKey)
 }
 
-func UploadByCLink(filePath, cLink string) (err error) {
-	return aStorage.UploadByCLink(filePath, cLink)
+func UploadByCLink(filePath, cLink string, options ...interface{}) (err error) {
+	return aStorage.UploadByCLink(filePath, cLink, options...)
+}
+
+//Open - open stored file by cLink for reading
+func Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	return aStorage.Open(cLink, options...)
+}
+
+//OpenRange - open part of stored file by cLink, length < 0 - to the end
+func OpenRange(cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error) {
+	return aStorage.OpenRange(cLink, offset, length, options...)
 }
 
 func GetPathByCLink(cLink string) (path string) {
 	return aStorage.GetPathByCLink(cLink)
 }
 
+
+//Stat - get info about stored file by cLink
+func Stat(cLink string) (info core.ObjectInfo, err error) {
+	return aStorage.Stat(cLink)
+}
+
+//List - call fn for every file in the storage which path starts with prefix
+func List(storageKey, prefix string, fn func(info core.ObjectInfo) error) (err error) {
+	return aStorage.List(storageKey, prefix, fn)
+}
+
+//UpdateMetadata - change headers and user metadata of stored file
+func UpdateMetadata(cLink string, options ...interface{}) (err error) {
+	return aStorage.UpdateMetadata(cLink, options...)
+}
+
+//DeleteMany - delete files from storages, results are in the order of cLinks
+func DeleteMany(cLinks []string) (results []core.DeleteResult) {
+	return aStorage.DeleteMany(cLinks)
+}
+
+//DeletePrefix - delete all files in the storage which path starts with prefix
+func DeletePrefix(storageKey, prefix string) (results []core.DeleteResult, err error) {
+	return aStorage.DeletePrefix(storageKey, prefix)
+}
+
+//Restore - bring back removed file from trash, see core.Restore
+func Restore(cLink string) (err error) {
+	return aStorage.Restore(cLink)
+}
+
+//PurgeTrash - remove files kept in trash of the storage longer than retention period
+func PurgeTrash(storageKey string) (purged int, err error) {
+	return aStorage.PurgeTrash(storageKey)
+}
+
+//ListVersions - list versions of stored file, the latest first
+func ListVersions(cLink string) (versions []core.VersionInfo, err error) {
+	return aStorage.ListVersions(cLink)
+}
+
+//RestoreVersion - make the version of stored file the latest one
+func RestoreVersion(cLink, versionID string) (err error) {
+	return aStorage.RestoreVersion(cLink, versionID)
+}
+
+//Close - stop background work of all storages, e.g. the janitor of local storage
+func Close() (err error) {
+	return aStorage.Close()
+}
+
+//SetVisibility - make stored file public (core.VisibilityPublic) or private (core.VisibilityPrivate)
+func SetVisibility(cLink string, v core.Visibility) (err error) {
+	return aStorage.SetVisibility(cLink, v)
+}
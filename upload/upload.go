@@ -0,0 +1,287 @@
+package upload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/quota"
+	"github.com/rosberry/storage/validation"
+)
+
+type (
+	Config struct {
+		Storage    *core.AbstractStorage
+		StorageKey string
+
+		// generator of paths by the name of the uploaded file,
+		// default - the generator of the storage set by SetPathGenerator
+		PathGenerator core.PathGenerator
+
+		FieldName    string   // form field with the file, default DefaultFieldName
+		MaxSize      int64    // bytes, default DefaultMaxSize
+		AllowedTypes []string // MIME types detected by content, "image/*" matches all images. Empty - any type
+
+		// Authorize is called before reading the body, the error is returned with 403 status. Nil - no authorization
+		Authorize func(r *http.Request) error
+
+		// Options returns Store and GetURL options for the request, e.g. core.PathVars of the user
+		Options func(r *http.Request) []interface{}
+
+		// Respond writes the response, default - JSON with Result or {"error": "..."} and the status of StatusCode
+		Respond func(w http.ResponseWriter, r *http.Request, result *Result, err error)
+
+		TempDir string // default os.TempDir()
+	}
+
+	Result struct {
+		CLink       string `json:"clink"`
+		URL         string `json:"url"`
+		Name        string `json:"name"`
+		Size        int64  `json:"size"`
+		ContentType string `json:"content_type"`
+	}
+
+	handler struct {
+		cfg Config
+	}
+)
+
+const (
+	DefaultFieldName       = "file"
+	DefaultMaxSize   int64 = 32 << 20
+
+	sniffLen = 512
+)
+
+var (
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrNoFile           = errors.New("no file in the form")
+	ErrTooLarge         = errors.New("file is too large")
+	ErrTypeNotAllowed   = errors.New("file type is not allowed")
+)
+
+// New returns handler of multipart/form-data POST requests: the file from FieldName is saved
+// to the temp file, checked by limits and stored to the storage by the generated path.
+// Parts before the file are skipped, the rest of the body is not read
+func New(cfg *Config) http.Handler {
+	h := &handler{cfg: *cfg}
+
+	if h.cfg.FieldName == "" {
+		h.cfg.FieldName = DefaultFieldName
+	}
+
+	if h.cfg.MaxSize <= 0 {
+		h.cfg.MaxSize = DefaultMaxSize
+	}
+
+	if h.cfg.Respond == nil {
+		h.cfg.Respond = RespondJSON
+	}
+
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	result, err := h.upload(r)
+	h.cfg.Respond(w, r, result, err)
+}
+
+func (h *handler) upload(r *http.Request) (*Result, error) {
+	if r.Method != http.MethodPost {
+		return nil, ErrMethodNotAllowed
+	}
+
+	if h.cfg.Authorize != nil {
+		if err := h.cfg.Authorize(r); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err) // nolint:errorlint
+		}
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoFile, err) // nolint:errorlint
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoFile
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read form: %w", err)
+		}
+
+		if part.FormName() != h.cfg.FieldName || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		defer part.Close()
+
+		return h.store(r, part, filepath.Base(part.FileName()))
+	}
+}
+
+// store copies the file to the temp file checking its size and type and stores it
+func (h *handler) store(r *http.Request, body io.Reader, name string) (*Result, error) {
+	f, err := os.CreateTemp(h.cfg.TempDir, "upload-*"+filepath.Ext(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, io.LimitReader(body, h.cfg.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if size > h.cfg.MaxSize {
+		return nil, fmt.Errorf("%w: max %d bytes", ErrTooLarge, h.cfg.MaxSize)
+	}
+
+	head := make([]byte, sniffLen)
+	n, _ := f.ReadAt(head, 0)
+	contentType := http.DetectContentType(head[:n])
+
+	if !h.allowed(contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotAllowed, contentType)
+	}
+
+	var options []interface{}
+	if h.cfg.Options != nil {
+		options = h.cfg.Options(r)
+	}
+
+	cLink, err := h.storeFile(f.Name(), name, append(options, core.ContentType(contentType)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		CLink:       cLink,
+		URL:         h.cfg.Storage.GetURL(cLink, options...),
+		Name:        name,
+		Size:        size,
+		ContentType: contentType,
+	}, nil
+}
+
+func (h *handler) storeFile(filePath, name string, options []interface{}) (cLink string, err error) {
+	if h.cfg.PathGenerator == nil {
+		cLink, err = h.cfg.Storage.PrepareCLinkAutoInStorage(name, h.cfg.StorageKey, options...)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate path: %w", err)
+		}
+
+		if err = h.cfg.Storage.UploadByCLink(filePath, cLink, options...); err != nil {
+			return "", fmt.Errorf("failed to store file: %w", err)
+		}
+
+		return cLink, nil
+	}
+
+	var vars map[string]string
+	for _, o := range options {
+		if v, ok := o.(core.PathVars); ok {
+			vars = v
+		}
+	}
+
+	path, err := h.cfg.PathGenerator.GeneratePath(name, vars)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate path: %w", err)
+	}
+
+	cLink, err = h.cfg.Storage.CreateCLinkInStorage(filePath, path, h.cfg.StorageKey, options...)
+	if err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+
+	return cLink, nil
+}
+
+func (h *handler) allowed(contentType string) bool {
+	if len(h.cfg.AllowedTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range h.cfg.AllowedTypes {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StatusCode returns HTTP status for the error of the upload,
+// files rejected by validation or quota of the wrapped storage are client errors
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusCreated
+	case errors.Is(err, ErrMethodNotAllowed):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusForbidden
+	case errors.Is(err, ErrNoFile):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, validation.ErrRejected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, quota.ErrQuotaExceeded):
+		var limitErr *quota.LimitError
+		if errors.As(err, &limitErr) && limitErr.Limit == quota.LimitObjectSize {
+			return http.StatusRequestEntityTooLarge
+		}
+
+		return http.StatusInsufficientStorage
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// RespondJSON - default response: Result or {"error": "..."}, the details of internal errors are logged
+func RespondJSON(w http.ResponseWriter, r *http.Request, result *Result, err error) {
+	status := StatusCode(err)
+
+	var body interface{} = result
+
+	if err != nil {
+		msg := err.Error()
+
+		if status == http.StatusInternalServerError {
+			log.Println("Failed to upload:", err)
+			msg = http.StatusText(status)
+		}
+
+		if status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", http.MethodPost)
+		}
+
+		body = map[string]string{"error": msg}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body) // nolint:errcheck
+}
@@ -0,0 +1,167 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+	"github.com/rosberry/storage/pathgen"
+	"github.com/rosberry/storage/quota"
+	"github.com/rosberry/storage/validation"
+)
+
+var testStorageKey = "uploads"
+
+func newTestRequest(field, name string, content []byte) *http.Request {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "avatar")
+
+	fw, _ := mw.CreateFormFile(field, name)
+	fw.Write(content)
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return r
+}
+
+func TestUpload(t *testing.T) {
+	aStorage := core.New()
+	aStorage.AddStorage(testStorageKey, local.New(&local.Config{
+		StorageKey: testStorageKey,
+		Endpoint:   "http://url.com/files",
+		Root:       t.TempDir(),
+		BufferSize: 32 * 1024,
+	}))
+	aStorage.SetPathGenerator(testStorageKey, &pathgen.Template{Template: "{user}/{uuid}{ext}"})
+
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), make([]byte, 100)...)
+
+	h := New(&Config{
+		Storage:      aStorage,
+		StorageKey:   testStorageKey,
+		MaxSize:      200,
+		AllowedTypes: []string{"image/*"},
+		Authorize: func(r *http.Request) error {
+			if r.Header.Get("Authorization") == "" {
+				return errors.New("no token")
+			}
+			return nil
+		},
+		Options: func(r *http.Request) []interface{} {
+			return []interface{}{core.PathVars{"user": "42"}}
+		},
+	})
+
+	flagtests := []struct {
+		name    string
+		request *http.Request
+		auth    bool
+		code    int
+	}{
+		{"image", newTestRequest("file", "photo.PNG", png), true, http.StatusCreated},
+		{"unauthorized", newTestRequest("file", "photo.png", png), false, http.StatusForbidden},
+		{"too large", newTestRequest("file", "photo.png", append(png, make([]byte, 200)...)), true, http.StatusRequestEntityTooLarge},
+		{"type", newTestRequest("file", "doc.png", []byte("plain text")), true, http.StatusUnsupportedMediaType},
+		{"no file", newTestRequest("other", "photo.png", png), true, http.StatusBadRequest},
+		{"method", httptest.NewRequest(http.MethodGet, "/upload", nil), true, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.auth {
+				tt.request.Header.Set("Authorization", "token")
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, tt.request)
+
+			if w.Code != tt.code {
+				t.Fatalf("got %d %q, want %d", w.Code, w.Body.String(), tt.code)
+			}
+
+			if tt.code != http.StatusCreated {
+				return
+			}
+
+			var result Result
+			json.Unmarshal(w.Body.Bytes(), &result)
+
+			if !strings.HasPrefix(result.CLink, testStorageKey+":42/") || !strings.HasSuffix(result.CLink, ".png") ||
+				result.URL != "http://url.com/files/"+strings.TrimPrefix(result.CLink, testStorageKey+":") ||
+				result.Size != int64(len(png)) || result.ContentType != "image/png" || result.Name != "photo.PNG" {
+				t.Errorf("unexpected result: %+v", result)
+			}
+
+			if info, err := aStorage.Stat(result.CLink); err != nil || info.ContentType != "image/png" {
+				t.Errorf("unexpected info: %+v, err %v", info, err)
+			}
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	var got error
+
+	h := New(&Config{
+		Storage: core.New(),
+		Respond: func(w http.ResponseWriter, r *http.Request, result *Result, err error) {
+			got = err
+			w.WriteHeader(http.StatusTeapot)
+		},
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newTestRequest("other", "a.txt", []byte("a")))
+
+	if w.Code != http.StatusTeapot || !errors.Is(got, ErrNoFile) {
+		t.Errorf("got %d, %v", w.Code, got)
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	flagtests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"ok", nil, http.StatusCreated},
+		{"rejected", fmt.Errorf("failed to store file: %w", &validation.RejectionError{Reason: validation.ReasonActiveContent}), http.StatusUnprocessableEntity},
+		{"object size", fmt.Errorf("failed to store file: %w", &quota.LimitError{Limit: quota.LimitObjectSize}), http.StatusRequestEntityTooLarge},
+		{"quota", fmt.Errorf("failed to store file: %w", &quota.LimitError{Limit: quota.LimitBytes}), http.StatusInsufficientStorage},
+		{"internal", errors.New("disk failed"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := StatusCode(tt.err); code != tt.code {
+				t.Errorf("got %d, want %d", code, tt.code)
+			}
+
+			if tt.err == nil {
+				return
+			}
+
+			w := httptest.NewRecorder()
+			RespondJSON(w, httptest.NewRequest(http.MethodPost, "/upload", nil), nil, tt.err)
+
+			var body map[string]string
+			json.Unmarshal(w.Body.Bytes(), &body)
+
+			if hidden := body["error"] != tt.err.Error(); hidden != (tt.code == http.StatusInternalServerError) {
+				t.Errorf("unexpected error message %q", body["error"])
+			}
+		})
+	}
+}
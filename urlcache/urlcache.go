@@ -0,0 +1,245 @@
+package urlcache
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	Config struct {
+		StorageCtl core.Storage
+		TTL        time.Duration // max lifetime of cached URL
+		Margin     time.Duration // signed URL is re-signed when it expires sooner than Margin
+		MaxEntries int
+	}
+
+	URLCacheStorage struct {
+		cfg Config
+
+		mu      sync.Mutex
+		entries map[string]map[string]entry // cLink -> options key -> URL
+		count   int
+	}
+
+	entry struct {
+		URL        string
+		expires    time.Time
+		urlExpires time.Time // expiry of the signed URL, zero if it is not known
+	}
+)
+
+const (
+	defaultTTL        = 10 * time.Minute
+	defaultMargin     = time.Minute
+	defaultMaxEntries = 100000
+)
+
+func New(cfg *Config) *URLCacheStorage {
+	c := &URLCacheStorage{
+		cfg:     *cfg,
+		entries: make(map[string]map[string]entry),
+	}
+
+	if c.cfg.TTL == 0 {
+		c.cfg.TTL = defaultTTL
+	}
+
+	if c.cfg.Margin == 0 {
+		c.cfg.Margin = defaultMargin
+	}
+
+	if c.cfg.MaxEntries == 0 {
+		c.cfg.MaxEntries = defaultMaxEntries
+	}
+
+	return c
+}
+
+func (c *URLCacheStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	cLink, err = c.cfg.StorageCtl.Store(filePath, path, options...)
+	if err != nil {
+		return "", err // nolint:wrapcheck
+	}
+
+	c.Invalidate(cLink)
+
+	return cLink, nil
+}
+
+func (c *URLCacheStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
+	c.Invalidate(cLink)
+
+	return c.cfg.StorageCtl.StoreByCLink(filePath, cLink, options...)
+}
+
+// GetURL returns cached URL for the same cLink and options until it is close to expiry.
+// Options are compared by their type and value, URLs with options of other types than basic ones
+// (e.g. pointers or funcs) are not cached. The URL signed for core.ExpirationVerifier is reused
+// if it expires not later than requested and not sooner than Margin before
+func (c *URLCacheStorage) GetURL(cLink string, options ...interface{}) string {
+	key, requested, ok := optionsKey(cLink, options)
+	if !ok {
+		return c.cfg.StorageCtl.GetURL(cLink, options...)
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[cLink][key]
+	c.mu.Unlock()
+
+	if ok && now.Before(e.expires) && fits(e.urlExpires, requested, c.cfg.Margin) {
+		return e.URL
+	}
+
+	URL := c.cfg.StorageCtl.GetURL(cLink, options...)
+	if URL == "" {
+		return ""
+	}
+
+	urlExpires := expiry(URL)
+
+	expires := now.Add(c.cfg.TTL)
+	if !urlExpires.IsZero() && urlExpires.Add(-c.cfg.Margin).Before(expires) {
+		expires = urlExpires.Add(-c.cfg.Margin)
+	}
+
+	if expires.After(now) {
+		c.set(cLink, key, entry{URL: URL, expires: expires, urlExpires: urlExpires})
+	}
+
+	return URL
+}
+
+func (c *URLCacheStorage) Remove(cLink string) (err error) {
+	c.Invalidate(cLink)
+
+	return c.cfg.StorageCtl.Remove(cLink) // nolint:wrapcheck
+}
+
+func (c *URLCacheStorage) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	for _, cLink := range cLinks {
+		c.Invalidate(cLink)
+	}
+
+	return core.RemoveMany(c.cfg.StorageCtl, cLinks)
+}
+
+func (c *URLCacheStorage) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	results, err = core.RemovePrefix(c.cfg.StorageCtl, prefix)
+
+	for _, r := range results {
+		c.Invalidate(r.CLink)
+	}
+
+	return results, err // nolint:wrapcheck
+}
+
+func (c *URLCacheStorage) GetCLink(path string) (cLink string) {
+	return c.cfg.StorageCtl.GetCLink(path)
+}
+
+// SetVisibility drops cached URLs of the cLink, they are signed or not depending on visibility
+func (c *URLCacheStorage) SetVisibility(cLink string, v core.Visibility) (err error) {
+	c.Invalidate(cLink)
+
+	return core.SetVisibility(c.cfg.StorageCtl, cLink, v) // nolint:wrapcheck
+}
+
+func (c *URLCacheStorage) Unwrap() core.Storage {
+	return c.cfg.StorageCtl
+}
+
+// Invalidate drops all cached URLs of the cLink
+func (c *URLCacheStorage) Invalidate(cLink string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.count -= len(c.entries[cLink])
+	delete(c.entries, cLink)
+}
+
+func (c *URLCacheStorage) set(cLink, key string, e entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.count >= c.cfg.MaxEntries {
+		c.sweep()
+	}
+
+	urls, ok := c.entries[cLink]
+	if !ok {
+		urls = make(map[string]entry)
+		c.entries[cLink] = urls
+	}
+
+	if _, ok = urls[key]; !ok {
+		c.count++
+	}
+
+	urls[key] = e
+}
+
+// sweep drops expired URLs, or all URLs if the cache is still full
+func (c *URLCacheStorage) sweep() {
+	now := time.Now()
+
+	for cLink, urls := range c.entries {
+		for key, e := range urls {
+			if !now.Before(e.expires) {
+				delete(urls, key)
+				c.count--
+			}
+		}
+
+		if len(urls) == 0 {
+			delete(c.entries, cLink)
+		}
+	}
+
+	if c.count >= c.cfg.MaxEntries {
+		c.entries = make(map[string]map[string]entry)
+		c.count = 0
+	}
+}
+
+// optionsKey returns the key of options and the requested expiry of the URL, not ok if options can't be keyed
+func optionsKey(cLink string, options []interface{}) (key string, expires time.Time, ok bool) {
+	parts := make([]string, 0, len(options))
+
+	for _, o := range options {
+		// the expiry is checked by the cached URL, signed and unsigned URLs are keyed separately
+		if v, ok := o.(core.ExpirationVerifier); ok {
+			expires = v.GetAccessExpireTime(cLink)
+			parts = append(parts, "expires")
+
+			continue
+		}
+
+		switch reflect.ValueOf(o).Kind() {
+		case reflect.Bool, reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			parts = append(parts, fmt.Sprintf("%T:%v", o, o))
+		default:
+			return "", expires, false
+		}
+	}
+
+	return strings.Join(parts, "|"), expires, true
+}
+
+// fits reports if the URL expiring at urlExpires can be returned for the requested expiry
+func fits(urlExpires, requested time.Time, margin time.Duration) bool {
+	if requested.IsZero() || urlExpires.IsZero() {
+		return true
+	}
+
+	return !urlExpires.After(requested) && !urlExpires.Before(requested.Add(-margin))
+}
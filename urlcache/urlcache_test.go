@@ -0,0 +1,186 @@
+package urlcache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rosberry/storage/bypass"
+	"github.com/rosberry/storage/core"
+)
+
+// countingStorage signs URLs which expire after lifetime
+type countingStorage struct {
+	*bypass.Bypass
+	lifetime time.Duration
+	calls    int
+}
+
+func (s *countingStorage) GetURL(cLink string, options ...interface{}) string {
+	s.calls++
+	return fmt.Sprintf("https://cdn.com/%s?Expires=%d&n=%d", cLink, time.Now().Add(s.lifetime).Unix(), s.calls)
+}
+
+func TestGetURL(t *testing.T) {
+	flagtests := []struct {
+		name     string
+		lifetime time.Duration
+		calls    int
+	}{
+		{"long living", time.Hour, 1},
+		{"close to expiry", 30 * time.Second, 3},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &countingStorage{lifetime: tt.lifetime}
+			c := New(&Config{StorageCtl: s, TTL: time.Hour, Margin: time.Minute})
+
+			first := c.GetURL("cfs:file.jpg")
+			c.GetURL("cfs:file.jpg")
+			last := c.GetURL("cfs:file.jpg")
+
+			if s.calls != tt.calls {
+				t.Errorf("got %d calls, want %d", s.calls, tt.calls)
+			}
+
+			if (first == last) != (tt.calls == 1) {
+				t.Errorf("unexpected URLs %q, %q", first, last)
+			}
+		})
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	s := &countingStorage{lifetime: time.Hour}
+	c := New(&Config{StorageCtl: s})
+
+	c.GetURL("cfs:file.jpg")
+	c.GetURL("cfs:file.jpg", "public")
+	c.GetURL("cfs:file.jpg", "public")
+
+	if s.calls != 2 {
+		t.Errorf("got %d calls, want 2", s.calls)
+	}
+
+	c.Invalidate("cfs:file.jpg")
+	c.GetURL("cfs:file.jpg")
+
+	if s.calls != 3 {
+		t.Errorf("got %d calls, want 3", s.calls)
+	}
+
+	// URL depends on visibility, the cache is dropped even if the storage can't change it
+	if err := c.SetVisibility("cfs:file.jpg", core.VisibilityPublic); !errors.Is(err, core.ErrNotSupported) {
+		t.Errorf("got %v, want %v", err, core.ErrNotSupported)
+	}
+
+	c.GetURL("cfs:file.jpg")
+
+	if s.calls != 4 {
+		t.Errorf("got %d calls, want 4", s.calls)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	flagtests := []struct {
+		in  string
+		out time.Time
+	}{
+		{"https://cdn.com/file.jpg", time.Time{}},
+		{"https://cdn.com/file.jpg?Expires=1700000000&Signature=x&Key-Pair-Id=y", time.Unix(1700000000, 0)},
+		{
+			"https://bucket.s3.amazonaws.com/file.jpg?X-Amz-Date=20261017T120000Z&X-Amz-Expires=" + strconv.Itoa(3600),
+			time.Date(2026, 10, 17, 13, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := expiry(tt.in); !got.Equal(tt.out) {
+				t.Errorf("got %v, want %v", got, tt.out)
+			}
+		})
+	}
+}
+
+type lifetime time.Duration
+
+func (l lifetime) GetAccessExpireTime(cLink string) time.Time {
+	return time.Now().Add(time.Duration(l))
+}
+
+// verifierStorage signs URLs for the requested expiry
+type verifierStorage struct {
+	*bypass.Bypass
+	calls int
+}
+
+func (s *verifierStorage) GetURL(cLink string, options ...interface{}) string {
+	s.calls++
+
+	for _, o := range options {
+		if v, ok := o.(core.ExpirationVerifier); ok {
+			return fmt.Sprintf("https://cdn.com/%s?Expires=%d", cLink, v.GetAccessExpireTime(cLink).Unix())
+		}
+	}
+
+	return "https://cdn.com/" + cLink
+}
+
+func TestRequestedExpiry(t *testing.T) {
+	s := &verifierStorage{}
+	c := New(&Config{StorageCtl: s, TTL: time.Hour, Margin: time.Minute})
+
+	flagtests := []struct {
+		name     string
+		lifetime time.Duration
+		calls    int
+	}{
+		{"first", time.Hour, 1},
+		{"same lifetime", time.Hour, 1},
+		{"longer", 2 * time.Hour, 2},
+		{"shorter", 10 * time.Minute, 3},
+		{"shorter again", 10 * time.Minute, 3},
+	}
+
+	for _, tt := range flagtests {
+		c.GetURL("cfs:file.jpg", lifetime(tt.lifetime))
+
+		if s.calls != tt.calls {
+			t.Errorf("%s: got %d calls, want %d", tt.name, s.calls, tt.calls)
+		}
+	}
+}
+
+func TestOptionsKey(t *testing.T) {
+	option := "a"
+
+	flagtests := []struct {
+		name    string
+		options []interface{}
+		calls   int
+	}{
+		{"basic", []interface{}{core.VisibilityPublic, 1}, 1},
+		{"pointer", []interface{}{&option}, 2},
+		{"func", []interface{}{func() {}}, 2},
+		{"nil", []interface{}{nil}, 2},
+	}
+
+	for _, tt := range flagtests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &countingStorage{lifetime: time.Hour}
+			c := New(&Config{StorageCtl: s})
+
+			c.GetURL("cfs:file.jpg", tt.options...)
+			c.GetURL("cfs:file.jpg", tt.options...)
+
+			if s.calls != tt.calls {
+				t.Errorf("got %d calls, want %d", s.calls, tt.calls)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,45 @@
+package urlcache
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const amzDateFormat = "20060102T150405Z"
+
+// expiry returns expiration time of signed URL or zero time for unsigned URL.
+// Supported are CloudFront canned policy and S3 presigned URLs (V2 and V4)
+func expiry(URL string) time.Time {
+	u, err := url.Parse(URL)
+	if err != nil {
+		return time.Time{}
+	}
+
+	q := u.Query()
+
+	if expires := q.Get("Expires"); expires != "" {
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return time.Time{}
+		}
+
+		return time.Unix(unix, 0)
+	}
+
+	if date, expires := q.Get("X-Amz-Date"), q.Get("X-Amz-Expires"); date != "" && expires != "" {
+		signed, err := time.Parse(amzDateFormat, date)
+		if err != nil {
+			return time.Time{}
+		}
+
+		seconds, err := strconv.Atoi(expires)
+		if err != nil {
+			return time.Time{}
+		}
+
+		return signed.Add(time.Duration(seconds) * time.Second)
+	}
+
+	return time.Time{}
+}
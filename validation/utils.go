@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const typeSVG = "image/svg+xml"
+
+var (
+	svgTag        = regexp.MustCompile(`(?i)<svg[\s>]`)
+	svgScriptable = regexp.MustCompile(`(?i)<script|<foreignobject|javascript:|\son[a-z]+\s*=|<html`)
+
+	activeExtensions = map[string]bool{
+		".html":  true,
+		".htm":   true,
+		".xhtml": true,
+		".shtml": true,
+		".js":    true,
+		".mjs":   true,
+	}
+
+	// sniffer can not tell these types from their container
+	containerTypes = map[string][]string{
+		"text/plain":      {"text/", "application/json", "application/xml", "application/javascript", "image/svg+xml"},
+		"text/xml":        {"text/xml", "application/xml", "image/svg+xml"},
+		"application/zip": {"application/zip", "application/vnd.openxmlformats", "application/vnd.oasis", "application/epub+zip", "application/java-archive"},
+	}
+
+	extensionTypes = map[string]string{
+		".jpg":  "image/jpeg",
+		".jpeg": "image/jpeg",
+		".png":  "image/png",
+		".gif":  "image/gif",
+		".webp": "image/webp",
+		".svg":  typeSVG,
+		".pdf":  "application/pdf",
+		".json": "application/json",
+		".txt":  "text/plain",
+		".csv":  "text/csv",
+		".mp4":  "video/mp4",
+		".mp3":  "audio/mpeg",
+		".zip":  "application/zip",
+	}
+)
+
+// detectContentType sniffs the media type, SVG is recognized in addition to http.DetectContentType
+func detectContentType(content []byte) string {
+	contentType := mediaType(http.DetectContentType(content))
+
+	if (contentType == "text/xml" || contentType == "text/plain") && svgTag.Match(content) {
+		return typeSVG
+	}
+
+	return contentType
+}
+
+// isActiveContent reports whether the content may run scripts in the browser,
+// SVG and XML are matched while reading r to the end
+func isActiveContent(p, contentType string, r io.RuneReader) (bool, error) {
+	if activeExtensions[strings.ToLower(path.Ext(p))] {
+		return true, nil
+	}
+
+	switch contentType {
+	case "text/html":
+		return true, nil
+	case typeSVG, "text/xml", "application/xml":
+		rr := &errRuneReader{r: r}
+		matched := svgScriptable.MatchReader(rr)
+
+		return matched, rr.err
+	}
+
+	return false, nil
+}
+
+// errRuneReader keeps the read error, MatchReader stops on it as on the end of the content
+type errRuneReader struct {
+	r   io.RuneReader
+	err error
+}
+
+func (e *errRuneReader) ReadRune() (r rune, size int, err error) {
+	r, size, err = e.r.ReadRune()
+	if err != nil && !errors.Is(err, io.EOF) {
+		e.err = err
+	}
+
+	return r, size, err
+}
+
+func allowed(allowedTypes []string, contentType string) bool {
+	for _, t := range allowedTypes {
+		if t == "*/*" || t == contentType {
+			return true
+		}
+
+		if strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extensionMatches reports whether the extension of p may hold content of contentType.
+// Unknown extensions and paths without extension are not checked
+func extensionMatches(p, contentType string) bool {
+	ext := strings.ToLower(path.Ext(p))
+	if ext == "" {
+		return true
+	}
+
+	expected, ok := extensionTypes[ext]
+	if !ok {
+		expected = mediaType(mime.TypeByExtension(ext))
+	}
+
+	if expected == "" || expected == contentType {
+		return true
+	}
+
+	for _, prefix := range containerTypes[contentType] {
+		if strings.HasPrefix(expected, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mediaType(contentType string) string {
+	return strings.TrimSpace(strings.ToLower(strings.Split(contentType, ";")[0]))
+}
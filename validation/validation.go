@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+type (
+	Config struct {
+		StorageKey         string
+		StorageCtl         core.Storage
+		AllowedTypes       []string // e.g. "image/*", "application/pdf", empty - any type
+		CheckExtension     bool     // extension of the path should match the detected type
+		BlockActiveContent bool     // reject HTML and SVG with scripts
+	}
+
+	ValidationStorage struct {
+		cfg Config
+	}
+
+	// RejectionError - detailed ErrRejected
+	RejectionError struct {
+		Path        string
+		ContentType string
+		Reason      string
+	}
+)
+
+const (
+	ReasonTypeNotAllowed    = "content type is not allowed"
+	ReasonExtensionMismatch = "extension does not match content"
+	ReasonActiveContent     = "active content is not allowed"
+
+	maxInspectedSize = 1 << 20
+)
+
+var ErrRejected = errors.New("file rejected")
+
+func New(cfg *Config) *ValidationStorage {
+	return &ValidationStorage{
+		cfg: *cfg,
+	}
+}
+
+func (e *RejectionError) Error() string {
+	return fmt.Sprintf("%v: %s: %s (%s)", ErrRejected, e.Path, e.Reason, e.ContentType)
+}
+
+func (e *RejectionError) Unwrap() error {
+	return ErrRejected
+}
+
+func (v *ValidationStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	if err = v.Validate(filePath, path, options...); err != nil {
+		return "", err
+	}
+
+	return v.cfg.StorageCtl.Store(filePath, path, options...)
+}
+
+func (v *ValidationStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
+	if err = v.Validate(filePath, common.CLinkToPath(v.cfg.StorageKey, cLink), options...); err != nil {
+		return err
+	}
+
+	return v.cfg.StorageCtl.StoreByCLink(filePath, cLink, options...)
+}
+
+func (v *ValidationStorage) GetURL(cLink string, options ...interface{}) string {
+	return v.cfg.StorageCtl.GetURL(cLink, options...)
+}
+
+func (v *ValidationStorage) Remove(cLink string) (err error) {
+	return v.cfg.StorageCtl.Remove(cLink) // nolint:wrapcheck
+}
+
+func (v *ValidationStorage) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	return core.RemoveMany(v.cfg.StorageCtl, cLinks)
+}
+
+func (v *ValidationStorage) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	return core.RemovePrefix(v.cfg.StorageCtl, prefix) // nolint:wrapcheck
+}
+
+func (v *ValidationStorage) GetCLink(path string) (cLink string) {
+	return v.cfg.StorageCtl.GetCLink(path)
+}
+
+func (v *ValidationStorage) Unwrap() core.Storage {
+	return v.cfg.StorageCtl
+}
+
+// Validate checks the file and the core.ContentType option against the policy,
+// returns *RejectionError for rejected files
+func (v *ValidationStorage) Validate(filePath, path string, options ...interface{}) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, maxInspectedSize))
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	contentType := detectContentType(content)
+	declared := mediaType(core.ParseStoreOptions(options...).ContentType)
+
+	reject := func(contentType, reason string) error {
+		return &RejectionError{Path: path, ContentType: contentType, Reason: reason}
+	}
+
+	if v.cfg.BlockActiveContent {
+		for i, t := range []string{contentType, declared} {
+			if t == "" || (i > 0 && t == contentType) {
+				continue
+			}
+
+			// scripts may follow any amount of padding, so the whole file is scanned
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+
+			active, err := isActiveContent(path, t, bufio.NewReader(f))
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+
+			if active {
+				return reject(t, ReasonActiveContent)
+			}
+		}
+	}
+
+	if len(v.cfg.AllowedTypes) > 0 && !allowed(v.cfg.AllowedTypes, contentType) {
+		return reject(contentType, ReasonTypeNotAllowed)
+	}
+
+	// the declared type is served to clients, it should be allowed too
+	if declared != "" && len(v.cfg.AllowedTypes) > 0 && !allowed(v.cfg.AllowedTypes, declared) {
+		return reject(declared, ReasonTypeNotAllowed)
+	}
+
+	if v.cfg.CheckExtension && !extensionMatches(path, contentType) {
+		return reject(contentType, ReasonExtensionMismatch)
+	}
+
+	return nil
+}
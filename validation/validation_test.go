@@ -0,0 +1,82 @@
+package validation
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rosberry/storage/core"
+	"github.com/rosberry/storage/local"
+)
+
+var (
+	testStorageKey = "checked"
+
+	pngContent = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+	jpgContent = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+	htmlPage   = []byte("<!DOCTYPE html><html><body><script>alert(1)</script></body></html>")
+	svgImage   = []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`)
+	svgScript  = []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"><rect/></svg>`)
+	svgPadded  = []byte(`<svg xmlns="http://www.w3.org/2000/svg"><!--` + strings.Repeat(" ", 2<<20) + `--><script>alert(1)</script></svg>`)
+)
+
+func TestValidate(t *testing.T) {
+	v := New(&Config{
+		StorageKey: testStorageKey,
+		StorageCtl: local.New(&local.Config{
+			StorageKey: testStorageKey,
+			Root:       t.TempDir(),
+			BufferSize: 32 * 1024,
+		}),
+		AllowedTypes:       []string{"image/*", "text/plain"},
+		CheckExtension:     true,
+		BlockActiveContent: true,
+	})
+
+	flagtests := []struct {
+		path    string
+		content []byte
+		options []interface{}
+		reason  string
+	}{
+		{"a.png", pngContent, nil, ""},
+		{"a.jpg", jpgContent, nil, ""},
+		{"a.jpeg", jpgContent, nil, ""},
+		{"noext", pngContent, nil, ""},
+		{"a.txt", []byte("hello"), nil, ""},
+		{"a.svg", svgImage, nil, ""},
+		{"a.jpg", pngContent, nil, ReasonExtensionMismatch},
+		{"a.jpg", htmlPage, nil, ReasonActiveContent},
+		{"a.html", []byte("hello"), nil, ReasonActiveContent},
+		{"a.svg", svgScript, nil, ReasonActiveContent},
+		{"a.pdf", []byte("%PDF-1.4"), nil, ReasonTypeNotAllowed},
+		{"a.svg", svgPadded, nil, ReasonActiveContent},
+		{"a.png", pngContent, []interface{}{core.ContentType("image/png")}, ""},
+		{"a.txt", []byte("hello"), []interface{}{core.ContentType("text/html; charset=utf-8")}, ReasonActiveContent},
+		{"a.png", pngContent, []interface{}{core.ContentType("application/pdf")}, ReasonTypeNotAllowed},
+	}
+
+	dir := t.TempDir()
+
+	for i, tt := range flagtests {
+		t.Run(tt.path+":"+tt.reason, func(t *testing.T) {
+			tmp := filepath.Join(dir, string(rune('a'+i)))
+			ioutil.WriteFile(tmp, tt.content, 0o644)
+
+			_, err := v.Store(tmp, tt.path, tt.options...)
+
+			var rejection *RejectionError
+
+			switch {
+			case tt.reason == "" && err != nil:
+				t.Errorf("Store err: %q", err)
+			case tt.reason != "" && !errors.As(err, &rejection):
+				t.Errorf("got %v, want %v", err, ErrRejected)
+			case tt.reason != "" && rejection.Reason != tt.reason:
+				t.Errorf("got %q, want %q", rejection.Reason, tt.reason)
+			}
+		})
+	}
+}
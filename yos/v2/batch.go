@@ -0,0 +1,95 @@
+package yos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// RemoveMany removes objects by RemoveObjects, minio splits them into requests with up to 1000 keys.
+// In trash mode objects are moved to trash one by one
+func (y *YandexObjStorage) RemoveMany(cLinks []string) (results []core.DeleteResult) {
+	results = make([]core.DeleteResult, len(cLinks))
+
+	keys := make([]string, 0, len(cLinks))
+	indexes := make(map[string][]int, len(cLinks))
+
+	for i, cLink := range cLinks {
+		results[i].CLink = cLink
+
+		if y.cfg.Trash {
+			results[i].Err = y.Remove(cLink)
+			continue
+		}
+
+		key := common.PathToInternalPath(y.cfg.Prefix, common.CLinkToPath(y.cfg.StorageKey, cLink))
+		if _, ok := indexes[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		indexes[key] = append(indexes[key], i)
+	}
+
+	for key, err := range y.removeObjects(keys) {
+		for _, i := range indexes[key] {
+			results[i].Err = err
+		}
+	}
+
+	return results
+}
+
+// RemovePrefix removes all objects which path starts with prefix
+func (y *YandexObjStorage) RemovePrefix(prefix string) (results []core.DeleteResult, err error) {
+	if strings.Trim(prefix, "/") == "" {
+		return nil, core.ErrEmptyPrefix
+	}
+
+	var cLinks []string
+
+	err = y.List(prefix, func(info core.ObjectInfo) error {
+		cLinks = append(cLinks, info.CLink)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return y.RemoveMany(cLinks), nil
+}
+
+// removeObjects removes keys, the result has an entry for every key
+func (y *YandexObjStorage) removeObjects(keys []string) map[string]error {
+	errs := make(map[string]error, len(keys))
+	if len(keys) == 0 {
+		return errs
+	}
+
+	objects := make(chan minio.ObjectInfo, len(keys))
+	for _, key := range keys {
+		objects <- minio.ObjectInfo{Key: key}
+		errs[key] = nil
+	}
+	close(objects)
+
+	for e := range y.client.RemoveObjects(context.Background(), y.cfg.BucketName, objects, minio.RemoveObjectsOptions{}) {
+		err := fmt.Errorf("failed remove object: %w", e.Err)
+
+		// error of the whole request has no object name
+		if e.ObjectName == "" {
+			for _, key := range keys {
+				errs[key] = err
+			}
+
+			continue
+		}
+
+		errs[e.ObjectName] = err
+	}
+
+	return errs
+}
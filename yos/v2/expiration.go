@@ -0,0 +1,88 @@
+package yos
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/rosberry/storage/common"
+)
+
+const noSuchLifecycleConfiguration = "NoSuchLifecycleConfiguration"
+
+// SetupExpirationRules adds lifecycle rules to the bucket for objects stored with core.ExpiresAt:
+// one rule for every day up to MaxExpirationDays and the rule purging trash in trash mode.
+// Rules not created by the storage are kept
+func (y *YandexObjStorage) SetupExpirationRules() (err error) {
+	ctx := context.Background()
+
+	config, err := y.client.GetBucketLifecycle(ctx, y.cfg.BucketName)
+	if minio.ToErrorResponse(err).Code == noSuchLifecycleConfiguration {
+		config, err = lifecycle.NewConfiguration(), nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed get lifecycle: %w", err)
+	}
+
+	rules := make([]lifecycle.Rule, 0, len(config.Rules)+y.maxExpirationDays())
+
+	for _, r := range config.Rules {
+		if !strings.HasPrefix(r.ID, common.ExpirationRulePrefix) {
+			rules = append(rules, r)
+		}
+	}
+
+	for days := 1; days <= y.maxExpirationDays(); days++ {
+		rules = append(rules, lifecycle.Rule{
+			ID:     common.ExpirationRuleID(days),
+			Status: "Enabled",
+			RuleFilter: lifecycle.Filter{
+				Tag: lifecycle.Tag{
+					Key:   common.ExpirationTag,
+					Value: strconv.Itoa(days),
+				},
+			},
+			Expiration: lifecycle.Expiration{
+				Days: lifecycle.ExpirationDays(days),
+			},
+		})
+	}
+
+	if y.cfg.Trash {
+		rules = append(rules, y.trashRule())
+	}
+
+	config.Rules = rules
+
+	if err = y.client.SetBucketLifecycle(ctx, y.cfg.BucketName, config); err != nil {
+		return fmt.Errorf("failed set lifecycle: %w", err)
+	}
+
+	return nil
+}
+
+func (y *YandexObjStorage) maxExpirationDays() int {
+	if y.cfg.MaxExpirationDays > 0 {
+		return y.cfg.MaxExpirationDays
+	}
+
+	return common.DefaultMaxExpirationDays
+}
+
+// expirationTags returns tags for the upload, nil for zero expiresAt
+func (y *YandexObjStorage) expirationTags(expiresAt time.Time) (map[string]string, error) {
+	if expiresAt.IsZero() {
+		return nil, nil
+	}
+
+	days, err := common.ExpirationDays(expiresAt, y.maxExpirationDays())
+	if err != nil {
+		return nil, err // nolint:wrapcheck
+	}
+
+	return map[string]string{common.ExpirationTag: strconv.Itoa(days)}, nil
+}
@@ -0,0 +1,161 @@
+package yos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// visibilityTag keeps public access of the object in trash, the copy in trash is private
+const visibilityTag = "storage-visibility"
+
+// Restore copies removed object from trash back to its path
+func (y *YandexObjStorage) Restore(cLink string) (err error) {
+	ctx := context.Background()
+	path := common.CLinkToPath(y.cfg.StorageKey, cLink)
+	internalPath := common.PathToInternalPath(y.cfg.Prefix, path)
+	trashPath := common.PathToInternalPath(y.cfg.Prefix, common.TrashPath(path))
+
+	t, err := y.client.GetObjectTagging(ctx, y.cfg.BucketName, trashPath, minio.GetObjectTaggingOptions{})
+	if isNotFound(err) {
+		return fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed get object tagging: %w", err)
+	}
+
+	_, err = y.client.StatObject(ctx, y.cfg.BucketName, internalPath, minio.StatObjectOptions{})
+	if err == nil {
+		return fmt.Errorf("%s: %w", cLink, core.ErrObjectExists)
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("failed stat object: %w", err)
+	}
+
+	dst := minio.CopyDestOptions{
+		Bucket:      y.cfg.BucketName,
+		Object:      internalPath,
+		ReplaceTags: true,
+	}
+
+	if t.ToMap()[visibilityTag] == string(core.VisibilityPublic) {
+		// metadata is copied from the source, only ACL header is added
+		info, err := y.Stat(common.PathToCLink(y.cfg.StorageKey, common.TrashPath(path)))
+		if err != nil {
+			return err
+		}
+
+		opts := core.MergeMetadata(info)
+		opts.Visibility = core.VisibilityPublic
+
+		if dst.UserMetadata, err = copyMetadata(opts); err != nil {
+			return err
+		}
+
+		dst.ReplaceMetadata = true
+	}
+
+	_, err = y.client.CopyObject(ctx, dst, minio.CopySrcOptions{Bucket: y.cfg.BucketName, Object: trashPath})
+	if err != nil {
+		return fmt.Errorf("failed restore object: %w", err)
+	}
+
+	if err = y.client.RemoveObject(ctx, y.cfg.BucketName, trashPath, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed remove object from trash: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeTrash removes objects kept in trash longer than TrashRetention.
+// Use it by schedule or let the bucket do it by SetupExpirationRules
+func (y *YandexObjStorage) PurgeTrash() (purged int, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	deadline := time.Now().Add(-common.GetTrashRetention(y.cfg.TrashRetention))
+
+	var keys []string
+
+	objects := y.client.ListObjects(ctx, y.cfg.BucketName, minio.ListObjectsOptions{
+		Prefix:    common.PrefixToInternalPrefix(y.cfg.Prefix, common.TrashDir+"/"),
+		Recursive: true,
+	})
+
+	for obj := range objects {
+		if obj.Err != nil {
+			return 0, fmt.Errorf("failed list trash: %w", obj.Err)
+		}
+
+		if obj.LastModified.Before(deadline) {
+			keys = append(keys, obj.Key)
+		}
+	}
+
+	for _, key := range keys {
+		if err = y.client.RemoveObject(ctx, y.cfg.BucketName, key, minio.RemoveObjectOptions{}); err != nil {
+			return purged, fmt.Errorf("failed purge trash: %w", err)
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// trash copies object to trash and removes it, LastModified of the copy is the deletion time
+func (y *YandexObjStorage) trash(path string) error {
+	ctx := context.Background()
+	internalPath := common.PathToInternalPath(y.cfg.Prefix, path)
+
+	v, err := y.visibility(internalPath)
+	if err != nil {
+		return err
+	}
+
+	// tags are replaced, so expiration rules do not remove the object from trash
+	_, err = y.client.CopyObject(ctx,
+		minio.CopyDestOptions{
+			Bucket:      y.cfg.BucketName,
+			Object:      common.PathToInternalPath(y.cfg.Prefix, common.TrashPath(path)),
+			ReplaceTags: true,
+			UserTags:    map[string]string{visibilityTag: string(v)},
+		},
+		minio.CopySrcOptions{
+			Bucket: y.cfg.BucketName,
+			Object: internalPath,
+		})
+	if err != nil {
+		return fmt.Errorf("failed move object to trash: %w", err)
+	}
+
+	if err = y.client.RemoveObject(ctx, y.cfg.BucketName, internalPath, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed remove object: %w", err)
+	}
+
+	return nil
+}
+
+// trashRule is the lifecycle rule removing objects from trash after TrashRetention
+func (y *YandexObjStorage) trashRule() lifecycle.Rule {
+	return lifecycle.Rule{
+		ID:     common.ExpirationRulePrefix + common.TrashDir[1:],
+		Status: "Enabled",
+		RuleFilter: lifecycle.Filter{
+			Prefix: common.PrefixToInternalPrefix(y.cfg.Prefix, common.TrashDir+"/"),
+		},
+		Expiration: lifecycle.Expiration{
+			Days: lifecycle.ExpirationDays(common.RetentionDays(y.cfg.TrashRetention)),
+		},
+	}
+}
+
+func isNotFound(err error) bool {
+	return err != nil && minio.ToErrorResponse(err).StatusCode == http.StatusNotFound
+}
@@ -2,11 +2,16 @@ package yos
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 
+	"github.com/minio/minio-go/v7"
 	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
 )
 
 func (y *YandexObjStorage) prepareURL(cLink string, options ...interface{}) string {
@@ -24,19 +29,32 @@ func (y *YandexObjStorage) prepareURL(cLink string, options ...interface{}) stri
 		}
 	}
 
-	// check exist object
-	headURL, err := y.client.PresignedHeadObject(
-		context.Background(),
-		y.cfg.BucketName,
-		internalPath,
-		checkExistLinkLifeTime,
-		nil)
-	if err != nil {
-		log.Printf("Failed generate presignedURL for check exist object: %v", err)
+	versionID := core.ParseVersion(options...)
+
+	v := core.ParseVisibility(options...)
+	if versionID != "" {
+		v = core.VisibilityPrivate
 	}
 
-	if !checkExistObject(headURL.String()) {
-		return ""
+	if v == "" {
+		var err error
+
+		v, err = y.visibility(internalPath)
+		if errors.Is(err, core.ErrObjectNotFound) {
+			return ""
+		}
+		if err != nil {
+			log.Printf("Failed get visibility: %v", err)
+		}
+	}
+
+	if v == core.VisibilityPublic {
+		return y.preparePublicURL(internalPath)
+	}
+
+	var reqParams url.Values
+	if versionID != "" {
+		reqParams = url.Values{"versionId": {versionID}}
 	}
 
 	presignedURL, err := y.client.PresignedGetObject(
@@ -44,9 +62,10 @@ func (y *YandexObjStorage) prepareURL(cLink string, options ...interface{}) stri
 		y.cfg.BucketName,
 		internalPath,
 		getObjectLinkLifeTime,
-		nil)
+		reqParams)
 	if err != nil {
 		log.Printf("Failed generate presignedURL: %v", err)
+		return ""
 	}
 
 	return presignedURL.String()
@@ -77,21 +96,110 @@ func (y *YandexObjStorage) preparePutObjectURL(internalPath string) string {
 	return presignedURL.String()
 }
 
-func checkExistObject(headURL string) (exist bool) {
-	req, err := http.NewRequestWithContext(context.TODO(), "HEAD", headURL, nil)
+// visibility is public if anyone can read the object
+func (y *YandexObjStorage) visibility(internalPath string) (v core.Visibility, err error) {
+	obj, err := y.client.GetObjectACL(context.Background(), y.cfg.BucketName, internalPath)
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%s: %w", internalPath, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed get ACL: %w", err)
+	}
+
+	for _, g := range obj.Grant {
+		if g.Grantee.URI == allUsersURI && (g.Permission == "READ" || g.Permission == "FULL_CONTROL") {
+			return core.VisibilityPublic, nil
+		}
+	}
+
+	return core.VisibilityPrivate, nil
+}
+
+// replaceMetadata copies the object onto itself with new headers, user metadata and ACL
+func (y *YandexObjStorage) replaceMetadata(internalPath string, opts core.StoreOptions) error {
+	metadata, err := copyMetadata(opts)
 	if err != nil {
-		log.Print(err)
+		return err
 	}
 
-	// send request with headers
-	client := &http.Client{}
+	_, err = y.client.CopyObject(context.Background(),
+		minio.CopyDestOptions{
+			Bucket:          y.cfg.BucketName,
+			Object:          internalPath,
+			UserMetadata:    metadata,
+			ReplaceMetadata: true,
+		},
+		minio.CopySrcOptions{
+			Bucket: y.cfg.BucketName,
+			Object: internalPath,
+		})
+	if err != nil {
+		return fmt.Errorf("failed update metadata: %w", err)
+	}
+
+	return nil
+}
 
-	resp, err := client.Do(req)
+// copyMetadata returns metadata for CopyObject with ReplaceMetadata:
+// standard headers are passed with user metadata, minio sends them as is
+func copyMetadata(opts core.StoreOptions) (map[string]string, error) {
+	metadata, err := userMetadata(opts)
 	if err != nil {
-		log.Print("failed do request: v", err)
-		return false
+		return nil, err
+	}
+
+	if metadata == nil {
+		metadata = make(map[string]string, 5)
+	}
+
+	for k, v := range map[string]string{
+		"Content-Type":        opts.ContentType,
+		"Content-Encoding":    opts.ContentEncoding,
+		"Cache-Control":       opts.CacheControl,
+		"Content-Disposition": opts.ContentDisposition,
+		"Content-Language":    opts.ContentLanguage,
+	} {
+		if v != "" {
+			metadata[k] = v
+		}
+	}
+
+	return metadata, nil
+}
+
+// userMetadata returns user metadata with ACL header for the visibility
+func userMetadata(opts core.StoreOptions) (map[string]string, error) {
+	if opts.Visibility == "" {
+		return opts.UserMetadata, nil
+	}
+
+	metadata := make(map[string]string, len(opts.UserMetadata)+1)
+	for k, v := range opts.UserMetadata {
+		metadata[k] = v
+	}
+
+	switch opts.Visibility {
+	case core.VisibilityPublic:
+		metadata[aclHeader] = "public-read"
+	case core.VisibilityPrivate:
+		metadata[aclHeader] = "private"
+	default:
+		return nil, fmt.Errorf("%s: %w", opts.Visibility, core.ErrInvalidVisibility)
+	}
+
+	return metadata, nil
+}
+
+// lowerKeys returns user metadata with lower case keys
+func lowerKeys(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
 	}
-	defer resp.Body.Close()
 
-	return resp.StatusCode == http.StatusOK
+	return out
 }
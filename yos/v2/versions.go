@@ -0,0 +1,106 @@
+package yos
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
+)
+
+// ListVersions lists versions and delete markers of the object, the latest first.
+// Versioning should be enabled in the bucket
+func (y *YandexObjStorage) ListVersions(cLink string) (versions []core.VersionInfo, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := common.CLinkToPath(y.cfg.StorageKey, cLink)
+	internalPath := common.PathToInternalPath(y.cfg.Prefix, path)
+
+	objects := y.client.ListObjects(ctx, y.cfg.BucketName, minio.ListObjectsOptions{
+		Prefix:       internalPath,
+		WithVersions: true,
+	})
+
+	for obj := range objects {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed list versions: %w", obj.Err)
+		}
+
+		if obj.Key != internalPath {
+			continue
+		}
+
+		versions = append(versions, core.VersionInfo{
+			ObjectInfo: core.ObjectInfo{
+				CLink:        common.PathToCLink(y.cfg.StorageKey, path),
+				Path:         path,
+				Size:         obj.Size,
+				ETag:         obj.ETag,
+				LastModified: obj.LastModified,
+			},
+			VersionID:      obj.VersionID,
+			IsLatest:       obj.IsLatest,
+			IsDeleteMarker: obj.IsDeleteMarker,
+		})
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+
+	return versions, nil
+}
+
+// RestoreVersion copies the version over the object, the copy becomes the latest version.
+// Public access of the current object is kept
+func (y *YandexObjStorage) RestoreVersion(cLink, versionID string) (err error) {
+	ctx := context.Background()
+	path := common.CLinkToPath(y.cfg.StorageKey, cLink)
+	internalPath := common.PathToInternalPath(y.cfg.Prefix, path)
+
+	info, err := y.client.StatObject(ctx, y.cfg.BucketName, internalPath, minio.StatObjectOptions{VersionID: versionID})
+	if isNotFound(err) {
+		return fmt.Errorf("%s: version %s: %w", cLink, versionID, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed stat version: %w", err)
+	}
+
+	dst := minio.CopyDestOptions{
+		Bucket: y.cfg.BucketName,
+		Object: internalPath,
+	}
+
+	// the latest version may be a delete marker without ACL
+	if v, _ := y.visibility(internalPath); v == core.VisibilityPublic {
+		opts := core.StoreOptions{
+			ContentType:        info.ContentType,
+			ContentEncoding:    info.Metadata.Get("Content-Encoding"),
+			CacheControl:       info.Metadata.Get("Cache-Control"),
+			ContentDisposition: info.Metadata.Get("Content-Disposition"),
+			ContentLanguage:    info.Metadata.Get("Content-Language"),
+			UserMetadata:       lowerKeys(info.UserMetadata),
+			Visibility:         v,
+		}
+
+		if dst.UserMetadata, err = copyMetadata(opts); err != nil {
+			return err
+		}
+
+		dst.ReplaceMetadata = true
+	}
+
+	_, err = y.client.CopyObject(ctx, dst, minio.CopySrcOptions{
+		Bucket:    y.cfg.BucketName,
+		Object:    internalPath,
+		VersionID: versionID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed restore version: %w", err)
+	}
+
+	return nil
+}
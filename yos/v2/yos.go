@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/rosberry/storage/common"
+	"github.com/rosberry/storage/core"
 )
 
 type (
@@ -23,12 +26,22 @@ type (
 		BucketName      string
 		Prefix          string
 		NoSSL           bool
+		PathPolicy      *common.PathPolicy // default common.DefaultPathPolicy
+
+		// max days of core.ExpiresAt, default common.DefaultMaxExpirationDays, see SetupExpirationRules
+		MaxExpirationDays int
+
+		// Remove moves objects to .trash/ prefix, they are purged after TrashRetention
+		// (default common.DefaultTrashRetention) by PurgeTrash or lifecycle rule, see SetupExpirationRules
+		Trash          bool
+		TrashRetention time.Duration
 	}
 
 	YandexObjStorage struct {
 		cfg      Config
 		scheme   string
 		endpoint string
+		policy   common.PathPolicy
 
 		client *minio.Client
 	}
@@ -44,9 +57,8 @@ const (
 )
 
 const (
-	putLinkLifeTime        = 30 * time.Minute
-	checkExistLinkLifeTime = 10 * time.Minute
-	getObjectLinkLifeTime  = 24 * time.Hour
+	putLinkLifeTime       = 30 * time.Minute
+	getObjectLinkLifeTime = 24 * time.Hour
 )
 
 const (
@@ -54,6 +66,9 @@ const (
 	SchemeHTTPWithoutSSL = "http"
 
 	endpoint = "storage.yandexcloud.net"
+
+	aclHeader   = "X-Amz-Acl"
+	allUsersURI = "http://acs.amazonaws.com/groups/global/AllUsers"
 )
 
 var (
@@ -72,6 +87,7 @@ func New(cfg *Config) *YandexObjStorage {
 		cfg:      *cfg,
 		scheme:   scheme,
 		endpoint: endpoint,
+		policy:   common.GetPathPolicy(cfg.PathPolicy),
 	}
 
 	minioClient, err := minio.New(y.endpoint, &minio.Options{
@@ -88,11 +104,36 @@ func New(cfg *Config) *YandexObjStorage {
 	return y
 }
 
-func (y *YandexObjStorage) Store(filePath, path string) (cLink string, err error) {
+func (y *YandexObjStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
+	path, err = y.policy.Normalize(path)
+	if err != nil {
+		return "", fmt.Errorf("failed store file: %w", err)
+	}
+
+	if y.cfg.Trash && common.IsTrashPath(path) {
+		return "", fmt.Errorf("failed store file: %s: %w", path, common.ErrInvalidPath)
+	}
+
 	f, _ := os.Open(filePath)
 	defer f.Close()
 
-	mimetype := common.GetFileContentType(f)
+	opts := core.ParseStoreOptions(options...)
+
+	metadata, err := userMetadata(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed store file: %w", err)
+	}
+
+	tags, err := y.expirationTags(opts.ExpiresAt)
+	if err != nil {
+		return "", fmt.Errorf("failed store file: %w", err)
+	}
+
+	mimetype := opts.ContentType
+	if mimetype == "" {
+		mimetype = common.GetFileContentType(f)
+	}
+
 	internalPath := common.PathToInternalPath(y.cfg.Prefix, path)
 
 	_, err = y.client.FPutObject(
@@ -100,7 +141,15 @@ func (y *YandexObjStorage) Store(filePath, path string) (cLink string, err error
 		y.cfg.BucketName,
 		internalPath,
 		filePath,
-		minio.PutObjectOptions{ContentType: mimetype})
+		minio.PutObjectOptions{
+			ContentType:        mimetype,
+			ContentEncoding:    opts.ContentEncoding,
+			CacheControl:       opts.CacheControl,
+			ContentDisposition: opts.ContentDisposition,
+			ContentLanguage:    opts.ContentLanguage,
+			UserMetadata:       metadata,
+			UserTags:           tags,
+		})
 	if err != nil {
 		log.Print(err)
 	}
@@ -119,20 +168,191 @@ func (y *YandexObjStorage) GetURL(cLink string, options ...interface{}) string {
 }
 
 func (y *YandexObjStorage) Remove(cLink string) (err error) {
-	return ErrMethodNotImplemented
+	path := common.CLinkToPath(y.cfg.StorageKey, cLink)
+
+	if y.cfg.Trash {
+		return y.trash(path)
+	}
+
+	err = y.client.RemoveObject(context.Background(), y.cfg.BucketName,
+		common.PathToInternalPath(y.cfg.Prefix, path), minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed remove object: %w", err)
+	}
+
+	return nil
 }
 
 func (y *YandexObjStorage) GetCLink(path string) (cLink string) {
+	path, err := y.policy.Normalize(path)
+	if err != nil {
+		return ""
+	}
+
 	return common.PathToCLink(y.cfg.StorageKey, path)
 }
 
-func (y *YandexObjStorage) StoreByCLink(filePath, cLink string) (err error) {
+func (y *YandexObjStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
 	path := common.CLinkToPath(y.cfg.StorageKey, cLink)
 
-	_, err = y.Store(filePath, path)
+	_, err = y.Store(filePath, path, options...)
 	if err != nil {
 		return fmt.Errorf("failed store file: %w", err)
 	}
 
 	return nil
 }
+
+func (y *YandexObjStorage) Open(cLink string, options ...interface{}) (rc io.ReadCloser, err error) {
+	return y.getObject(cLink, minio.GetObjectOptions{VersionID: core.ParseVersion(options...)})
+}
+
+// OpenRange reads the part of the object by Range header, length < 0 - to the end
+func (y *YandexObjStorage) OpenRange(cLink string, offset, length int64, options ...interface{}) (rc io.ReadCloser, err error) {
+	opts := minio.GetObjectOptions{VersionID: core.ParseVersion(options...)}
+
+	switch {
+	case length == 0:
+		return http.NoBody, nil
+	case length > 0:
+		err = opts.SetRange(offset, offset+length-1)
+	case offset > 0:
+		err = opts.SetRange(offset, 0)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed get object: %w", err)
+	}
+
+	return y.getObject(cLink, opts)
+}
+
+func (y *YandexObjStorage) getObject(cLink string, opts minio.GetObjectOptions) (rc io.ReadCloser, err error) {
+	path := common.CLinkToPath(y.cfg.StorageKey, cLink)
+	internalPath := common.PathToInternalPath(y.cfg.Prefix, path)
+
+	obj, err := y.client.GetObject(context.Background(), y.cfg.BucketName, internalPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed get object: %w", err)
+	}
+
+	// minio returns request errors only on first access to the object
+	if _, err = obj.Stat(); err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("failed get object: %w", err)
+	}
+
+	return obj, nil
+}
+
+func (y *YandexObjStorage) Stat(cLink string) (info core.ObjectInfo, err error) {
+	path := common.CLinkToPath(y.cfg.StorageKey, cLink)
+	internalPath := common.PathToInternalPath(y.cfg.Prefix, path)
+
+	obj, err := y.client.StatObject(context.Background(), y.cfg.BucketName, internalPath, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return info, fmt.Errorf("%s: %w", cLink, core.ErrObjectNotFound)
+	}
+	if err != nil {
+		return info, fmt.Errorf("failed stat object: %w", err)
+	}
+
+	return core.ObjectInfo{
+		CLink:              common.PathToCLink(y.cfg.StorageKey, path),
+		Path:               path,
+		Size:               obj.Size,
+		ContentType:        obj.ContentType,
+		ContentEncoding:    obj.Metadata.Get("Content-Encoding"),
+		CacheControl:       obj.Metadata.Get("Cache-Control"),
+		ContentDisposition: obj.Metadata.Get("Content-Disposition"),
+		ContentLanguage:    obj.Metadata.Get("Content-Language"),
+		Metadata:           lowerKeys(obj.UserMetadata),
+		ETag:               obj.ETag,
+		LastModified:       obj.LastModified,
+		ExpiresAt:          obj.Expiration,
+	}, nil
+}
+
+// UpdateMetadata replaces headers and user metadata by copying the object onto itself,
+// see core.MergeMetadata
+func (y *YandexObjStorage) UpdateMetadata(cLink string, options ...interface{}) (err error) {
+	info, err := y.Stat(cLink)
+	if err != nil {
+		return err
+	}
+
+	opts := core.MergeMetadata(info, options...)
+	internalPath := common.PathToInternalPath(y.cfg.Prefix, info.Path)
+
+	// copy gets the default ACL, public access is kept explicitly
+	v, err := y.visibility(internalPath)
+	if err != nil {
+		return err
+	}
+
+	if v == core.VisibilityPublic {
+		opts.Visibility = v
+	}
+
+	return y.replaceMetadata(internalPath, opts)
+}
+
+// GetVisibility is public if anyone can read the object by ACL
+func (y *YandexObjStorage) GetVisibility(cLink string) (v core.Visibility, err error) {
+	path := common.CLinkToPath(y.cfg.StorageKey, cLink)
+
+	return y.visibility(common.PathToInternalPath(y.cfg.Prefix, path))
+}
+
+// SetVisibility changes ACL of the object: public-read or private.
+// The object is copied onto itself with the same metadata
+func (y *YandexObjStorage) SetVisibility(cLink string, v core.Visibility) (err error) {
+	if v == "" {
+		return fmt.Errorf("%s: empty visibility: %w", cLink, core.ErrInvalidVisibility)
+	}
+
+	info, err := y.Stat(cLink)
+	if err != nil {
+		return err
+	}
+
+	opts := core.MergeMetadata(info)
+	opts.Visibility = v
+
+	return y.replaceMetadata(common.PathToInternalPath(y.cfg.Prefix, info.Path), opts)
+}
+
+func (y *YandexObjStorage) List(prefix string, fn func(info core.ObjectInfo) error) (err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects := y.client.ListObjects(ctx, y.cfg.BucketName, minio.ListObjectsOptions{
+		Prefix:    common.PrefixToInternalPrefix(y.cfg.Prefix, prefix),
+		Recursive: true,
+	})
+
+	for obj := range objects {
+		if obj.Err != nil {
+			return fmt.Errorf("failed list objects: %w", obj.Err)
+		}
+
+		path := common.InternalPathToPath(y.cfg.Prefix, obj.Key)
+		if y.cfg.Trash && common.IsTrashPath(path) {
+			continue
+		}
+
+		err = fn(core.ObjectInfo{
+			CLink:        common.PathToCLink(y.cfg.StorageKey, path),
+			Path:         path,
+			Size:         obj.Size,
+			ContentType:  obj.ContentType,
+			ETag:         obj.ETag,
+			LastModified: obj.LastModified,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -67,7 +67,7 @@ func (y *YandexObjStorage) GetCLink(path string) (cLink string) {
 	return fmt.Sprintf("%s:%s", y.cfg.StorageKey, path)
 }
 
-func (y *YandexObjStorage) Store(filePath, path string) (cLink string, err error) {
+func (y *YandexObjStorage) Store(filePath, path string, options ...interface{}) (cLink string, err error) {
 	// Initialize minio client object.
 	minioClient, err := minio.New(y.endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(y.cfg.AccessKeyID, y.cfg.SecretAccessKey, ""),
@@ -170,6 +170,6 @@ func checkExistObject(headUrl string) (exist bool) {
 	return true
 }
 
-func (y *YandexObjStorage) StoreByCLink(filePath, cLink string) (err error) {
+func (y *YandexObjStorage) StoreByCLink(filePath, cLink string, options ...interface{}) (err error) {
 	return nil
 }
\ No newline at end of file